@@ -0,0 +1,60 @@
+package client
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+
+	"github.com/caelisco/http-client/response"
+)
+
+// newHash returns the hash.Hash for a checksum algorithm.
+// Supported algorithms are md5, sha1, sha256, sha512 and crc32c.
+func newHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm: %s", algorithm)
+}
+
+// verifyChecksum compares the computed sum with the expected digest sent by the server.
+// The digest may be hex or base64 encoded. A list of algorithm=digest pairs, as used by
+// x-goog-hash, is also accepted, in which case the pair matching the algorithm is used.
+func verifyChecksum(expected string, algorithm string, sum []byte) error {
+	digest := ""
+	parts := strings.Split(expected, ",")
+	for _, part := range parts {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && strings.EqualFold(name, algorithm) {
+			digest = value
+			break
+		}
+	}
+	// A single value is the digest itself
+	if digest == "" && len(parts) == 1 {
+		digest = strings.TrimSpace(expected)
+	}
+	if digest == "" {
+		return &response.ChecksumError{Algorithm: algorithm, Expected: expected, Actual: hex.EncodeToString(sum)}
+	}
+	if strings.EqualFold(digest, hex.EncodeToString(sum)) || digest == base64.StdEncoding.EncodeToString(sum) {
+		return nil
+	}
+	return &response.ChecksumError{Algorithm: algorithm, Expected: digest, Actual: hex.EncodeToString(sum)}
+}
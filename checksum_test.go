@@ -0,0 +1,47 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caelisco/http-client/request"
+	"github.com/caelisco/http-client/response"
+)
+
+func TestVerifyTrailerChecksum(t *testing.T) {
+	body := []byte("trailer checked body")
+	sum := sha256.Sum256(body)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write(body)
+		switch r.URL.Path {
+		case "/valid":
+			w.Header().Set("X-Checksum", "sha256="+base64.StdEncoding.EncodeToString(sum[:]))
+		case "/invalid":
+			w.Header().Set("X-Checksum", "sha256="+base64.StdEncoding.EncodeToString(make([]byte, sha256.Size)))
+		}
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.VerifyTrailerChecksum("X-Checksum", "sha256")
+	resp, err := Get(server.URL+"/valid", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != string(body) {
+		t.Fatalf("unexpected body %q", resp.String())
+	}
+
+	var checksumErr *response.ChecksumError
+	if _, err = Get(server.URL+"/invalid", opt); !errors.As(err, &checksumErr) {
+		t.Fatalf("expected a *response.ChecksumError for a mismatch, got %v", err)
+	}
+	if _, err = Get(server.URL+"/missing", opt); !errors.As(err, &checksumErr) || checksumErr.Expected != "" {
+		t.Fatalf("expected a *response.ChecksumError for a missing trailer, got %v", err)
+	}
+}
@@ -1,7 +1,15 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/caelisco/http-client/form"
 	"github.com/caelisco/http-client/kv"
@@ -10,16 +18,20 @@ import (
 
 // Client represents an HTTP client.
 type Client struct {
-	client    *http.Client   // HTTP client used to make requests.
-	responses []Response     // Store responses for reference.
-	global    RequestOptions // Global request options applied to all requests.
+	client      *http.Client      // HTTP client used to make requests.
+	responses   []Response        // Store responses for reference.
+	global      RequestOptions    // Global request options applied to all requests.
+	mu          sync.Mutex        // Guards the global options, responses and the idempotency cache.
+	idempotency *idempotencyCache // Recently completed responses by idempotency key, if enabled.
+	limiter     *rateLimiter      // Limits the rate requests are sent, if enabled.
+	baseURL     *url.URL          // Base that relative URLs are resolved against, if set.
 }
 
 // New returns a reusable Client.
 // It is possible to include a global RequestOptions which will be used on all subsequent requests.
 func New(options ...RequestOptions) *Client {
 	c := &Client{
-		client: &http.Client{},
+		client: &http.Client{Transport: newTransport()},
 	}
 	// if no options are passed through, use the defaults
 	if len(options) == 0 {
@@ -30,8 +42,28 @@ func New(options ...RequestOptions) *Client {
 	return c
 }
 
+// newTransport returns a transport with the defaults of the http.DefaultTransport and a TLS session cache,
+// so reconnecting to a host resumes the TLS session instead of performing a full handshake.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(0)}
+	return transport
+}
+
+// NewWithBaseURL returns a reusable Client which resolves relative URLs against base, so a request can
+// be made with only the path, i.e.: c.Get("/users/42"). See SetBaseURL for how URLs are resolved.
+// An error is returned if base is not an absolute URL.
+func NewWithBaseURL(base string, options ...RequestOptions) (*Client, error) {
+	c := New(options...)
+	if err := c.SetBaseURL(base); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 // NewCustom returns a reusable client with a custom defined *http.Client
-// This is useful in scenarios where you want to change any configurations for the http.Client
+// This is useful in scenarios where you want to change any configurations for the http.Client,
+// i.e.: a Jar to keep cookies between requests.
 func NewCustom(client *http.Client, options ...RequestOptions) *Client {
 	c := New(options...)
 	c.client = client
@@ -40,39 +72,220 @@ func NewCustom(client *http.Client, options ...RequestOptions) *Client {
 
 // GetGlobalOptions returns the global RequestOptions of the client.
 func (c *Client) GetGlobalOptions() RequestOptions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.global
 }
 
 // AddGlobalOptions adds the provided options to the client's global options
 func (c *Client) AddGlobalOptions(options RequestOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.global.Merge(options)
 }
 
 // UpdateGlobalOptions updates the global RequestOptions of the client.
 func (c *Client) UpdateGlobalOptions(options RequestOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.global = options
 }
 
+// SetDefaultHeader sets a header which is sent with every subsequent request made by the client.
+// A header of the same key set on an individual request takes priority.
+func (c *Client) SetDefaultHeader(key string, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.global.Headers = setHeader(c.global.Headers, http.CanonicalHeaderKey(key), value)
+}
+
+// setHeader returns a copy of the headers with the value of key replaced, or added if it is not present.
+// The key is case insensitive. A copy is returned so options sharing the slice are not modified.
+func setHeader(headers []kv.Header, key string, value string) []kv.Header {
+	headers = slices.DeleteFunc(slices.Clone(headers), func(h kv.Header) bool {
+		return strings.EqualFold(h.Key, key)
+	})
+	return append(headers, kv.Header{Key: key, Value: value})
+}
+
+// SetDefaultHeaders sets the headers which are sent with every subsequent request made by the client.
+// Only the first value of each header is used. A header of the same key set on an individual
+// request takes priority.
+func (c *Client) SetDefaultHeaders(headers http.Header) {
+	for key, values := range headers {
+		if len(values) > 0 {
+			c.SetDefaultHeader(key, values[0])
+		}
+	}
+}
+
+// WithRequestID returns a client which sends the id as the X-Request-ID header of every request, so the
+// requests of one operation share a stable correlation ID. Each request keeps its own X-TraceID.
+// The header is sent again when a redirect is followed. The returned client shares the underlying
+// *http.Client and idempotency cache, but keeps its own global options and responses. A cookie jar
+// set on either client afterwards only applies to that client.
+func (c *Client) WithRequestID(id string) *Client {
+	opt := c.CloneGlobalOptions()
+	opt.Headers = setHeader(opt.Headers, "X-Request-ID", id)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &Client{
+		client:      c.client,
+		global:      opt,
+		idempotency: c.idempotency,
+		limiter:     c.limiter,
+		baseURL:     c.baseURL,
+	}
+}
+
 // CloneGlobalOptions clones the global RequestOptions of the client.
 func (c *Client) CloneGlobalOptions() RequestOptions {
-	opt := RequestOptions{}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	opt := c.global
 	// Create a new slice and copy the elements to the new slice
 	opt.Headers = make([]kv.Header, len(c.global.Headers))
 	copy(opt.Headers, c.global.Headers)
 	opt.Cookies = make([]*http.Cookie, len(c.global.Cookies))
 	copy(opt.Cookies, c.global.Cookies)
+	if c.global.QueryParams != nil {
+		opt.SetQueryParams(c.global.QueryParams)
+	}
+	// Merge replaces and appends in place, so every other slice is copied as well
+	opt.HeaderFuncs = slices.Clone(c.global.HeaderFuncs)
+	opt.AllowedHosts = slices.Clone(c.global.AllowedHosts)
+	opt.AllowedSchemes = slices.Clone(c.global.AllowedSchemes)
+	opt.ClientCertificates = slices.Clone(c.global.ClientCertificates)
+	opt.LogFields = slices.Clone(c.global.LogFields)
+	opt.RedirectCodes = slices.Clone(c.global.RedirectCodes)
+	opt.RequestInterceptors = slices.Clone(c.global.RequestInterceptors)
+	opt.RequestTransforms = slices.Clone(c.global.RequestTransforms)
+	opt.ResponseInterceptors = slices.Clone(c.global.ResponseInterceptors)
+	opt.ResponseTransforms = slices.Clone(c.global.ResponseTransforms)
 
 	return opt
 }
 
+// SetCookieJar sets the jar used to store cookies received in responses and send them on later
+// requests to a matching domain and path, i.e.: a jar from net/http/cookiejar, or a FileCookieJar to keep
+// cookies between runs. A nil jar stops cookies being stored. Cookies set with RequestOptions.AddCookie
+// are sent as well as those from the jar. The *http.Client passed to NewCustom is not modified.
+func (c *Client) SetCookieJar(jar http.CookieJar) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The *http.Client is replaced rather than modified, as it may be in use or belong to the caller
+	client := *c.client
+	client.Jar = jar
+	c.client = &client
+}
+
+// Cookies returns the cookies in the jar which would be sent to the URL, or nil if there is no jar.
+func (c *Client) Cookies(u *url.URL) []*http.Cookie {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client.Jar == nil {
+		return nil
+	}
+	return c.client.Jar.Cookies(u)
+}
+
+// EnableIdempotencyCache keeps the response of each successful request carrying an idempotency key
+// for the ttl. A request made with the same key within the ttl returns the kept response rather than
+// being sent again, protecting against duplicate submissions such as a double click. A request made while
+// another with the same key is in flight waits for, and returns, the result of that request.
+// Requests without an idempotency key, and streamed responses, are not affected.
+func (c *Client) EnableIdempotencyCache(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.idempotency = newIdempotencyCache(ttl)
+}
+
+// SetBaseURL sets the base that URLs without a scheme are resolved against with url.ResolveReference.
+// URLs with a scheme are used unchanged. As with a link in a page, a path starting with / replaces the
+// path of the base, so end the base with / to resolve paths relative to it, i.e.: https://api.example.com/v1/
+// with users/42. A query string on the base is kept and sent before the query of the URL.
+// An empty base removes it. An error is returned if base is not an absolute URL.
+func (c *Client) SetBaseURL(base string) error {
+	var u *url.URL
+	if base != "" {
+		var err error
+		u, err = url.Parse(base)
+		if err != nil {
+			return fmt.Errorf("invalid base url: %w", err)
+		}
+		if !u.IsAbs() || u.Host == "" {
+			return fmt.Errorf("invalid base url %q: must be an absolute url with a scheme and host", base)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.baseURL = u
+	return nil
+}
+
+// SetRateLimit limits the client to sending rps requests per second, allowing bursts of up to burst
+// requests. A request blocks until it is allowed to be sent. The limit is shared by all goroutines
+// using the client. A rps of zero or less removes the limit.
+func (c *Client) SetRateLimit(rps float64, burst int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rps <= 0 {
+		c.limiter = nil
+		return
+	}
+	c.limiter = newRateLimiter(rps, burst)
+}
+
 // Clear clears any Responses that have already been made and kept.
 func (c *Client) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.responses = nil
 }
 
 // Responses returns a slice of responses made by this Client
 func (c *Client) Responses() []Response {
-	return c.responses
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Limit the capacity so a later append does not write in to the returned slice
+	return c.responses[:len(c.responses):len(c.responses)]
+}
+
+// ResponsesByStatus returns the responses made by this Client with the given status code.
+func (c *Client) ResponsesByStatus(code int) []Response {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var matched []Response
+	for _, resp := range c.responses {
+		if resp.StatusCode == code {
+			matched = append(matched, resp)
+		}
+	}
+	return matched
+}
+
+// FailedResponses returns the responses made by this Client which failed with an error,
+// or received a status code of 400 or above.
+func (c *Client) FailedResponses() []Response {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var failed []Response
+	for _, resp := range c.responses {
+		if resp.Error != nil || resp.StatusCode >= 400 {
+			failed = append(failed, resp)
+		}
+	}
+	return failed
+}
+
+// LastResponse returns the most recent response made by this Client, and false if there is none.
+func (c *Client) LastResponse() (Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.responses) == 0 {
+		return Response{}, false
+	}
+	return c.responses[len(c.responses)-1], true
 }
 
 func (c *Client) doRequest(method string, url string, payload []byte, options ...RequestOptions) (Response, error) {
@@ -80,18 +293,39 @@ func (c *Client) doRequest(method string, url string, payload []byte, options ..
 	opt := c.CloneGlobalOptions()
 
 	// Merge the local RequestOptions with the global RequestOptions
-	if len(options) == 0 {
-		opt = request.NewOptions()
-	} else {
+	if len(options) > 0 {
 		opt.Merge(options[0])
 	}
 
-	// Perform the request with the merged options
-	response, err := doRequest(c.client, method, url, payload, opt)
+	c.mu.Lock()
+	client, cache, limiter, base := c.client, c.idempotency, c.limiter, c.baseURL
+	c.mu.Unlock()
+	if base != nil {
+		var err error
+		if url, err = resolveBaseURL(base, url); err != nil {
+			return Response{}, err
+		}
+	}
+	send := func() (Response, error) {
+		// Wait for the rate limit before the request goes out
+		if limiter != nil {
+			limiter.wait()
+		}
+
+		// Perform the request with the merged options
+		response, err := doRequest(client, method, url, payload, opt)
 
-	// Keep the response
-	c.responses = append(c.responses, response)
-	return response, err
+		// Keep the response
+		c.mu.Lock()
+		c.responses = append(c.responses, response)
+		c.mu.Unlock()
+		return response, err
+	}
+	// Return the response of a request already completed, or still in flight, with the same idempotency key
+	if cache != nil && opt.IdempotencyKey != "" && !opt.StreamOutput {
+		return cache.do(opt.IdempotencyKey, opt.Now, send)
+	}
+	return send()
 }
 
 // Get performs an HTTP GET to the specified URL.
@@ -102,6 +336,24 @@ func (c *Client) Get(url string, opt ...RequestOptions) (Response, error) {
 	return c.doRequest(http.MethodGet, url, nil, opt...)
 }
 
+// GetAll performs an HTTP GET to the specified URL and follows the pages returned by the pagination cursor.
+// See GetAll for how the next page is found.
+// Returns the pages fetched so far and an error if any.
+func (c *Client) GetAll(ctx context.Context, url string, opt ...RequestOptions) ([]Response, error) {
+	// The cursor may be set on the global RequestOptions or those of the request
+	option := c.CloneGlobalOptions()
+	if len(opt) > 0 {
+		option.Merge(opt[0])
+	}
+	cursor, err := paginationCursor(option)
+	if err != nil {
+		return nil, err
+	}
+	return paginate(ctx, url, cursor, func(url string) (Response, error) {
+		return c.doRequest(http.MethodGet, url, nil, opt...)
+	})
+}
+
 // Post performs an HTTP POST to the specified URL with the given payload.
 // It accepts the URL string as its first argument and the payload as the second argument.
 // Optionally, you can provide additional RequestOptions to customize the request.
@@ -127,6 +379,37 @@ func (c *Client) FormData(url string, payload map[string]string, opt ...RequestO
 	return c.doRequest(http.MethodPost, url, form.Encode(payload), opt...)
 }
 
+// MultipartUpload performs an HTTP POST as a multipart/form-data payload to the specified URL.
+// It accepts the URL string as its first argument and a map[string]any as the payload.
+// Values of type string are sent as form fields, while values of type *os.File and form.FilePart are
+// streamed from disk as files. Use RequestOptions.OnFileUploadProgress to follow the progress of each file.
+// Optionally, you can provide additional RequestOptions to customize the request.
+// Returns the HTTP response and an error if any.
+func (c *Client) MultipartUpload(url string, payload map[string]any, opt ...RequestOptions) (Response, error) {
+	return c.doRequest(http.MethodPost, url, nil, multipartOptions(fieldParts(payload), opt...))
+}
+
+// MultipartUploadParts works as MultipartUpload but writes the parts in the order given,
+// i.e.: for a server which requires signature fields to be sent before the file.
+func (c *Client) MultipartUploadParts(url string, parts []form.Part, opt ...RequestOptions) (Response, error) {
+	return c.doRequest(http.MethodPost, url, nil, multipartOptions(parts, opt...))
+}
+
+// Relay performs an HTTP GET to srcURL and streams the response body as the payload of a request
+// to dstURL using the given method, typically POST or PUT. The body is never buffered in memory.
+// See Relay for how headers are copied and which RequestOptions apply to the source.
+// Returns the HTTP response from dstURL and an error if any.
+func (c *Client) Relay(srcURL string, dstURL string, method string, opt ...RequestOptions) (Response, error) {
+	option, src, err := relayOptions(func(option RequestOptions) (Response, error) {
+		return c.doRequest(http.MethodGet, srcURL, nil, option)
+	}, srcURL, dstURL, opt...)
+	if err != nil {
+		return Response{}, err
+	}
+	defer src.Close()
+	return c.doRequest(method, dstURL, nil, option)
+}
+
 // Put performs an HTTP PUT to the specified URL with the given payload.
 // It accepts the URL string as its first argument and the payload as the second argument.
 // Optionally, you can provide additional RequestOptions to customize the request.
@@ -172,7 +455,7 @@ func (c *Client) Head(url string, opt ...RequestOptions) (Response, error) {
 // Optionally, you can provide additional RequestOptions to customize the request.
 // Returns the HTTP response and an error if any.
 func (c *Client) Options(url string, opt ...RequestOptions) (Response, error) {
-	return c.doRequest(http.MethodHead, url, nil, opt...)
+	return c.doRequest(http.MethodOptions, url, nil, opt...)
 }
 
 // Trace performs an HTTP TRACE to the specified URL.
@@ -0,0 +1,325 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caelisco/http-client/request"
+)
+
+func TestSetDefaultHeaderKeepsDisableRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/start" {
+			http.Redirect(w, r, "/end", http.StatusFound)
+			return
+		}
+		w.Write([]byte(r.Header.Get("X-Default")))
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.DisableRedirect = true
+	c := New(opt)
+	c.SetDefaultHeader("X-Default", "set")
+
+	resp, err := c.Get(server.URL + "/start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected the redirect not to be followed, got %d", resp.StatusCode)
+	}
+	if got := resp.SentHeaders.Get("X-Default"); got != "set" {
+		t.Fatalf("expected the default header to be sent, got %q", got)
+	}
+
+	id := c.WithRequestID("abc")
+	resp, err = id.Get(server.URL + "/start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected WithRequestID to keep redirects disabled, got %d", resp.StatusCode)
+	}
+	if got := resp.SentHeaders.Get("X-Request-ID"); got != "abc" {
+		t.Fatalf("expected X-Request-ID abc, got %q", got)
+	}
+}
+
+func TestRequestHeaderFuncDoesNotReplaceGlobal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Signature")))
+	}))
+	defer server.Close()
+
+	global := request.NewOptions()
+	global.AddHeaderFunc("X-Signature", func() string { return "global" })
+	c := New(global)
+
+	local := request.NewOptions()
+	local.AddHeaderFunc("X-Signature", func() string { return "local" })
+	resp, err := c.Get(server.URL, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "local" {
+		t.Fatalf("expected the request header func to be used, got %q", resp.String())
+	}
+
+	resp, err = c.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "global" {
+		t.Fatalf("expected the global header func to be kept, got %q", resp.String())
+	}
+}
+
+func TestSetCookieJarDoesNotModifyCustomClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+	}))
+	defer server.Close()
+
+	custom := &http.Client{}
+	c := NewCustom(custom)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Get(server.URL)
+		}()
+	}
+	jar, _ := cookiejar.New(nil)
+	c.SetCookieJar(jar)
+	wg.Wait()
+
+	if custom.Jar != nil {
+		t.Fatal("expected the caller's *http.Client to be left unchanged")
+	}
+	if _, err := c.Get(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	if len(c.Cookies(u)) != 1 {
+		t.Fatalf("expected the jar to keep the session cookie, got %v", c.Cookies(u))
+	}
+}
+
+func TestMethodsSendTheirHTTPMethod(t *testing.T) {
+	var method string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+	}))
+	defer server.Close()
+
+	c := New()
+	tests := []struct {
+		want string
+		fn   func(string) (Response, error)
+	}{
+		{http.MethodGet, func(u string) (Response, error) { return Get(u) }},
+		{http.MethodPost, func(u string) (Response, error) { return Post(u, nil) }},
+		{http.MethodPut, func(u string) (Response, error) { return Put(u, nil) }},
+		{http.MethodPatch, func(u string) (Response, error) { return Patch(u, nil) }},
+		{http.MethodDelete, func(u string) (Response, error) { return Delete(u) }},
+		{http.MethodHead, func(u string) (Response, error) { return Head(u) }},
+		{http.MethodOptions, func(u string) (Response, error) { return Options(u) }},
+		{http.MethodTrace, func(u string) (Response, error) { return Trace(u) }},
+		{"PURGE", func(u string) (Response, error) { return Custom("PURGE", u, nil) }},
+		{http.MethodGet, func(u string) (Response, error) { return c.Get(u) }},
+		{http.MethodPost, func(u string) (Response, error) { return c.Post(u, nil) }},
+		{http.MethodPut, func(u string) (Response, error) { return c.Put(u, nil) }},
+		{http.MethodPatch, func(u string) (Response, error) { return c.Patch(u, nil) }},
+		{http.MethodDelete, func(u string) (Response, error) { return c.Delete(u) }},
+		{http.MethodHead, func(u string) (Response, error) { return c.Head(u) }},
+		{http.MethodOptions, func(u string) (Response, error) { return c.Options(u) }},
+		{http.MethodTrace, func(u string) (Response, error) { return c.Trace(u) }},
+		{"PURGE", func(u string) (Response, error) { return c.Custom("PURGE", u, nil) }},
+	}
+	for i, test := range tests {
+		method = ""
+		if _, err := test.fn(server.URL); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if method != test.want {
+			t.Errorf("%d: expected %s to reach the server, got %s", i, test.want, method)
+		}
+	}
+}
+
+func TestSetTimeoutAppliesPerRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(500 * time.Millisecond):
+			}
+		}
+	}))
+	defer server.Close()
+
+	c := New()
+	short := request.NewOptions()
+	short.SetTimeout(100 * time.Millisecond)
+	start := time.Now()
+	if _, err := c.Get(server.URL+"/slow", short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the slow request to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Fatalf("expected the request to stop at its timeout, took %s", elapsed)
+	}
+
+	// The timeout of one request leaves the client shared with other requests unchanged
+	resp, err := c.Get(server.URL + "/fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	generous := request.NewOptions()
+	generous.SetTimeout(5 * time.Second)
+	if _, err = c.Get(server.URL+"/slow", generous); err != nil {
+		t.Fatalf("expected the slow request to complete within a longer timeout, got %v", err)
+	}
+}
+
+func TestSetRateLimitIsSharedByGoroutines(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// A burst of 1 at 10 requests per second sends 10 requests in at least 900ms
+	c := New()
+	c.SetRateLimit(10, 1)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Get(server.URL); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+		t.Fatalf("expected the requests to be limited, 10 were sent in %s", elapsed)
+	}
+
+	c.SetRateLimit(0, 0)
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		if _, err := c.Get(server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected removing the limit to send requests without waiting, took %s", elapsed)
+	}
+}
+
+func TestRequestCannotWidenClientAllowlist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	global := request.NewOptions()
+	global.AllowHosts("example.com")
+	c := New(global)
+
+	local := request.NewOptions()
+	local.AllowHosts("127.0.0.1")
+	var notAllowed *request.URLNotAllowedError
+	if _, err := c.Get(server.URL, local); !errors.As(err, &notAllowed) {
+		t.Fatalf("expected a host outside the client allowlist to be rejected, got %v", err)
+	}
+
+	// Without a client allowlist, the allowlist of the request applies
+	c = New()
+	if _, err := c.Get(server.URL, local); err != nil {
+		t.Fatal(err)
+	}
+	local = request.NewOptions()
+	local.AllowHosts("example.com")
+	if _, err := c.Get(server.URL, local); !errors.As(err, &notAllowed) {
+		t.Fatalf("expected a host outside the request allowlist to be rejected, got %v", err)
+	}
+}
+
+func TestRequestHeaderOverridesDefaultHeader(t *testing.T) {
+	var values []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values = r.Header.Values("X-Mode")
+	}))
+	defer server.Close()
+
+	c := New()
+	c.SetDefaultHeaders(http.Header{"X-Mode": {"global"}})
+	if _, err := c.Get(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 || values[0] != "global" {
+		t.Fatalf("expected the default header, got %q", values)
+	}
+
+	for _, key := range []string{"X-Mode", "x-mode"} {
+		opt := request.NewOptions()
+		opt.AddHeader(key, "local")
+		if _, err := c.Get(server.URL, opt); err != nil {
+			t.Fatal(err)
+		}
+		if len(values) != 1 || values[0] != "local" {
+			t.Fatalf("%s: expected the request header to replace the default header, got %q", key, values)
+		}
+	}
+}
+
+func TestBaseURLResolvesRelativeURLs(t *testing.T) {
+	var received string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Host + " " + r.URL.RequestURI()
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	other := httptest.NewServer(handler)
+	defer other.Close()
+
+	c, err := NewWithBaseURL(server.URL + "/api/?key=v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	otherHost := strings.TrimPrefix(other.URL, "http://")
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/users/42", host + " /users/42?key=v"},
+		{"users/42", host + " /api/users/42?key=v"},
+		{"users?page=2", host + " /api/users?key=v&page=2"},
+		{other.URL + "/x", otherHost + " /x"},
+		{"/users/42", host + " /users/42?key=v"},
+	}
+	for _, test := range tests {
+		if _, err = c.Get(test.url); err != nil {
+			t.Fatalf("%s: %v", test.url, err)
+		}
+		if received != test.want {
+			t.Errorf("%s: expected %q, got %q", test.url, test.want, received)
+		}
+	}
+
+	if _, err = NewWithBaseURL("/relative"); err == nil {
+		t.Fatal("expected an error for a base which is not absolute")
+	}
+}
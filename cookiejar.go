@@ -0,0 +1,152 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileCookieJar is an http.CookieJar which keeps its cookies in a file, so a session can be reused
+// between runs, i.e.: a CLI tool which logs in once. Cookies are matched to requests by a cookiejar.Jar
+// and the file is rewritten each time the server sets a cookie. Cookies which have expired are not
+// loaded. Use it with Client.SetCookieJar or as the Jar of an http.Client.
+// It is safe for concurrent use, but the file should not be shared by running processes.
+type FileCookieJar struct {
+	mu      sync.Mutex
+	path    string
+	jar     *cookiejar.Jar
+	cookies map[string]storedCookie
+	err     error
+}
+
+// storedCookie is a cookie as it is saved to the file, with the URL of the response which set it.
+type storedCookie struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+// NewFileCookieJar returns a FileCookieJar which keeps its cookies in the file at path.
+// Cookies already in the file are loaded. A file which does not exist is created when the first cookie is set.
+func NewFileCookieJar(path string) (*FileCookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	fj := &FileCookieJar{path: path, jar: jar, cookies: make(map[string]storedCookie)}
+	if err = fj.load(time.Now()); err != nil {
+		return nil, err
+	}
+	return fj, nil
+}
+
+// SetCookies stores the cookies received from u and saves the jar to its file.
+// An error saving the file is returned by Err.
+func (fj *FileCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	fj.mu.Lock()
+	defer fj.mu.Unlock()
+	fj.jar.SetCookies(u, cookies)
+	now := time.Now()
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	for _, c := range cookies {
+		stored := *c
+		stored.Raw = ""
+		// A relative Max-Age is kept as an absolute expiry so it holds between runs
+		if stored.MaxAge > 0 {
+			stored.Expires = now.Add(time.Duration(stored.MaxAge) * time.Second)
+			stored.MaxAge = 0
+		}
+		key := cookieKey(u, &stored)
+		if c.MaxAge < 0 || (!stored.Expires.IsZero() && !stored.Expires.After(now)) {
+			delete(fj.cookies, key)
+			continue
+		}
+		fj.cookies[key] = storedCookie{URL: origin, Cookie: &stored}
+	}
+	fj.err = fj.save()
+}
+
+// Cookies returns the cookies to send in a request for u.
+func (fj *FileCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	fj.mu.Lock()
+	defer fj.mu.Unlock()
+	return fj.jar.Cookies(u)
+}
+
+// Err returns the error from the last time the jar was saved, if any.
+func (fj *FileCookieJar) Err() error {
+	fj.mu.Lock()
+	defer fj.mu.Unlock()
+	return fj.err
+}
+
+// load reads the cookies in the file in to the jar, skipping any which have expired.
+func (fj *FileCookieJar) load(now time.Time) error {
+	b, err := os.ReadFile(fj.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var stored []storedCookie
+	if err = json.Unmarshal(b, &stored); err != nil {
+		return err
+	}
+	for _, sc := range stored {
+		if sc.Cookie == nil || (!sc.Cookie.Expires.IsZero() && !sc.Cookie.Expires.After(now)) {
+			continue
+		}
+		u, err := url.Parse(sc.URL)
+		if err != nil {
+			return err
+		}
+		fj.jar.SetCookies(u, []*http.Cookie{sc.Cookie})
+		fj.cookies[cookieKey(u, sc.Cookie)] = sc
+	}
+	return nil
+}
+
+// save writes the cookies to a temporary file which replaces the file, so it is never left partly written.
+func (fj *FileCookieJar) save() error {
+	stored := make([]storedCookie, 0, len(fj.cookies))
+	for _, sc := range fj.cookies {
+		stored = append(stored, sc)
+	}
+	b, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(fj.path), filepath.Base(fj.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err = os.Rename(f.Name(), fj.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// cookieKey identifies a cookie by its domain, path and name, as a later cookie with the same key replaces it.
+func cookieKey(u *url.URL, c *http.Cookie) string {
+	domain := c.Domain
+	if domain == "" {
+		domain = u.Hostname()
+	}
+	return domain + ";" + c.Path + ";" + c.Name
+}
@@ -0,0 +1,24 @@
+package client
+
+import "errors"
+
+// ErrMaxRedirects is returned when a request is redirected more than the redirect limit allows.
+var ErrMaxRedirects = errors.New("max redirects exceeded")
+
+// ErrRedirectLoop is returned when a redirect leads back to a method and URL already visited
+// during the same request.
+var ErrRedirectLoop = errors.New("redirect loop detected")
+
+// ErrResponseTooLarge is returned when the decoded response body is larger than the maximum set
+// with SetMaxResponseSize.
+var ErrResponseTooLarge = errors.New("response body exceeds the maximum size")
+
+// ErrMessageTooLarge is returned by Subscribe when a message is larger than the maximum set with
+// SetMaxMessageSize.
+var ErrMessageTooLarge = errors.New("message exceeds the maximum size")
+
+// ErrNoContentLength is returned by RemoteFileSize when the server does not send a Content-Length.
+var ErrNoContentLength = errors.New("server did not send a Content-Length")
+
+// errBodyNotSent stops a body stream when the request returns before the whole body has been sent.
+var errBodyNotSent = errors.New("request returned before the body was sent")
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/caelisco/http-client/form"
+	"github.com/caelisco/http-client/request"
+)
+
+// PostFormValues performs an HTTP POST as an x-www-form-urlencoded payload to the specified URL.
+// Unlike FormPost, a field may have multiple values, i.e.: tag=a&tag=b.
+// Optionally, you can provide additional RequestOptions to customize the request.
+// Returns the HTTP response and an error if any.
+func PostFormValues(url string, values url.Values, opt ...RequestOptions) (Response, error) {
+	return doRequest(client, http.MethodPost, url, form.EncodeValues(values), formOptions(opt...))
+}
+
+// PutFormValues performs an HTTP PUT as an x-www-form-urlencoded payload to the specified URL.
+// See PostFormValues for how the values are sent.
+func PutFormValues(url string, values url.Values, opt ...RequestOptions) (Response, error) {
+	return doRequest(client, http.MethodPut, url, form.EncodeValues(values), formOptions(opt...))
+}
+
+// PatchFormValues performs an HTTP PATCH as an x-www-form-urlencoded payload to the specified URL.
+// See PostFormValues for how the values are sent.
+func PatchFormValues(url string, values url.Values, opt ...RequestOptions) (Response, error) {
+	return doRequest(client, http.MethodPatch, url, form.EncodeValues(values), formOptions(opt...))
+}
+
+// PostFormValues performs an HTTP POST as an x-www-form-urlencoded payload to the specified URL.
+// See PostFormValues for how the values are sent.
+func (c *Client) PostFormValues(url string, values url.Values, opt ...RequestOptions) (Response, error) {
+	return c.doRequest(http.MethodPost, url, form.EncodeValues(values), formOptions(opt...))
+}
+
+// PutFormValues performs an HTTP PUT as an x-www-form-urlencoded payload to the specified URL.
+// See PostFormValues for how the values are sent.
+func (c *Client) PutFormValues(url string, values url.Values, opt ...RequestOptions) (Response, error) {
+	return c.doRequest(http.MethodPut, url, form.EncodeValues(values), formOptions(opt...))
+}
+
+// PatchFormValues performs an HTTP PATCH as an x-www-form-urlencoded payload to the specified URL.
+// See PostFormValues for how the values are sent.
+func (c *Client) PatchFormValues(url string, values url.Values, opt ...RequestOptions) (Response, error) {
+	return c.doRequest(http.MethodPatch, url, form.EncodeValues(values), formOptions(opt...))
+}
+
+// formOptions returns the RequestOptions for an x-www-form-urlencoded payload.
+func formOptions(opt ...RequestOptions) RequestOptions {
+	var option RequestOptions
+	if len(opt) == 0 {
+		option = request.NewOptions()
+	} else {
+		option = opt[0]
+	}
+	if !option.HasHeader("Content-Type") {
+		option.AddHeader("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return option
+}
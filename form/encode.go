@@ -15,3 +15,9 @@ func Encode(m map[string]string) []byte {
 	}
 	return []byte(strings.Join(encoded, "&"))
 }
+
+// EncodeValues encodes the values as an x-www-form-urlencoded payload.
+// Unlike Encode, a field may have multiple values, i.e.: tag=a&tag=b. Fields are sorted by key.
+func EncodeValues(v url.Values) []byte {
+	return []byte(v.Encode())
+}
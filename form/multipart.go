@@ -0,0 +1,145 @@
+package form
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileProgress is called as the contents of a file are written to a multipart body.
+// A totalBytes of -1 means the size of the file is not known.
+type FileProgress func(field, filename string, bytesRead, totalBytes int64)
+
+// Part is a single field of a multipart body. Value is a string, *os.File or FilePart as accepted by Multipart.
+type Part struct {
+	Field string
+	Value any
+}
+
+// Multipart returns the Content-Type, including the boundary, and a function which streams the
+// fields as a multipart/form-data body in to an io.Writer.
+// Values of type string are written as form fields, while values of type *os.File and FilePart are written as files.
+// The files are read as the body is written and are not closed.
+// If onProgress is not nil it is called as each file is written.
+// The order of the fields is not defined, use MultipartParts when the server requires an order.
+func Multipart(fields map[string]any, onProgress FileProgress) (string, func(io.Writer) error) {
+	parts := make([]Part, 0, len(fields))
+	for field, value := range fields {
+		parts = append(parts, Part{Field: field, Value: value})
+	}
+	return MultipartParts(parts, onProgress)
+}
+
+// MultipartParts works as Multipart but writes the parts in the order given,
+// i.e.: for a server which requires signature fields to be sent before the file.
+func MultipartParts(parts []Part, onProgress FileProgress) (string, func(io.Writer) error) {
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	contentType := "multipart/form-data; boundary=" + boundary
+
+	return contentType, func(w io.Writer) error {
+		mw := multipart.NewWriter(w)
+		if err := mw.SetBoundary(boundary); err != nil {
+			return err
+		}
+		for _, p := range parts {
+			switch v := p.Value.(type) {
+			case string:
+				if err := mw.WriteField(p.Field, v); err != nil {
+					return err
+				}
+			case *os.File:
+				if err := writeFile(mw, p.Field, v, onProgress); err != nil {
+					return err
+				}
+			case FilePart:
+				if err := writeFilePart(mw, p.Field, v, onProgress); err != nil {
+					return err
+				}
+			case *FilePart:
+				if err := writeFilePart(mw, p.Field, *v, onProgress); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unsupported multipart value type %T for field %s", p.Value, p.Field)
+			}
+		}
+		return mw.Close()
+	}
+}
+
+// FilePart is a file sent as part of a multipart body from memory or any io.Reader,
+// i.e.: a rendered PDF, without writing it to disk first.
+// When used as a value in the map passed to Multipart, the map key is used if Field is empty.
+// An empty ContentType defaults to application/octet-stream.
+type FilePart struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Data        io.Reader
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func writeFile(mw *multipart.Writer, field string, f *os.File, onProgress FileProgress) error {
+	total := int64(-1)
+	if fi, err := f.Stat(); err == nil {
+		total = fi.Size()
+	}
+	return writePart(mw, FilePart{Field: field, Filename: filepath.Base(f.Name()), Data: f}, total, onProgress)
+}
+
+func writeFilePart(mw *multipart.Writer, field string, fp FilePart, onProgress FileProgress) error {
+	if fp.Field == "" {
+		fp.Field = field
+	}
+	// The size is known for in-memory readers such as bytes.Reader and strings.Reader
+	total := int64(-1)
+	if l, ok := fp.Data.(interface{ Len() int }); ok {
+		total = int64(l.Len())
+	}
+	return writePart(mw, fp, total, onProgress)
+}
+
+func writePart(mw *multipart.Writer, fp FilePart, total int64, onProgress FileProgress) error {
+	contentType := fp.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(fp.Field), quoteEscaper.Replace(fp.Filename)))
+	h.Set("Content-Type", contentType)
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	r := fp.Data
+	if onProgress != nil {
+		r = &progressReader{reader: fp.Data, total: total, onProgress: func(read, total int64) {
+			onProgress(fp.Field, fp.Filename, read, total)
+		}}
+	}
+	_, err = io.Copy(part, r)
+	return err
+}
+
+// progressReader reports the bytes read from the underlying reader.
+type progressReader struct {
+	reader     io.Reader
+	read       int64
+	total      int64
+	onProgress func(read, total int64)
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.reader.Read(p)
+	if n > 0 {
+		pr.read += int64(n)
+		pr.onProgress(pr.read, pr.total)
+	}
+	return n, err
+}
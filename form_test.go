@@ -0,0 +1,124 @@
+package client
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caelisco/http-client/form"
+	"github.com/caelisco/http-client/request"
+)
+
+func TestMultipartUploadPartsKeepsOrder(t *testing.T) {
+	var order []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			order = append(order, part.FormName())
+		}
+	}))
+	defer server.Close()
+
+	parts := []form.Part{
+		{Field: "key", Value: "uploads/file.txt"},
+		{Field: "policy", Value: "signed policy"},
+		{Field: "signature", Value: "abc"},
+		{Field: "file", Value: form.FilePart{Filename: "file.txt", Data: strings.NewReader("content")}},
+	}
+	resp, err := MultipartUploadParts(server.URL, parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", resp.StatusCode, resp.String())
+	}
+	if got := strings.Join(order, ","); got != "key,policy,signature,file" {
+		t.Fatalf("expected the parts in the order given, got %s", got)
+	}
+}
+
+func TestPostFormValuesSendsRepeatedValues(t *testing.T) {
+	var contentType string
+	var received url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		r.ParseForm()
+		received = r.PostForm
+	}))
+	defer server.Close()
+
+	values := url.Values{
+		"tag":   {"a", "b & c"},
+		"query": {"x=1?y=2"},
+	}
+	if _, err := PostFormValues(server.URL, values); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/x-www-form-urlencoded" {
+		t.Fatalf("unexpected Content-Type %q", contentType)
+	}
+	if !reflect.DeepEqual(received, values) {
+		t.Fatalf("expected %v, got %v", values, received)
+	}
+}
+
+func TestCompressedMultipartUpload(t *testing.T) {
+	var encoding string
+	var fields map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = gz
+		if err = r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fields = map[string]string{"name": r.FormValue("name")}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		fields["file"] = string(content)
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.Compress(request.CompressionGzip)
+	payload := map[string]any{
+		"name": "report",
+		"file": form.FilePart{Filename: "report.txt", Data: strings.NewReader("file content")},
+	}
+	resp, err := MultipartUpload(server.URL, payload, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", resp.StatusCode, resp.String())
+	}
+	if encoding != "gzip" {
+		t.Fatalf("expected Content-Encoding gzip, got %q", encoding)
+	}
+	if fields["name"] != "report" || fields["file"] != "file content" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+}
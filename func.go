@@ -1,15 +1,20 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
-	"compress/gzip"
-	"compress/zlib"
+	"context"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
-	"github.com/andybalholm/brotli"
 	"github.com/caelisco/http-client/form"
 	"github.com/caelisco/http-client/request"
 	"github.com/caelisco/http-client/response"
@@ -23,17 +28,22 @@ const (
 	SchemeWSS   string = "wss://"
 )
 
+// redirectLimit is the default limit on the number of redirects followed for one request.
+const redirectLimit = 10
+
 // A global default client is used for all of the method-based requests.
 var client = &http.Client{
 	Timeout: 30 * time.Second, // Set an appropriate timeout
 }
 
+// streamClient is used by Subscribe and Relay, which stream a body for as long as the server keeps sending,
+// so unlike the default client it has no overall timeout. RequestOptions.Timeout bounds the request.
+var streamClient = &http.Client{}
+
 // doRequest performs the actual underlying HTTP request. RequestOptions are optional.
 // If no protocol scheme is detected, it will automatically upgrade to https://
 // Use RequestOptions.ProtocolScheme to define a different protocol
-func doRequest(client *http.Client, method string, url string, payload []byte, options ...request.Options) (Response, error) {
-	start := time.Now()
-
+func doRequest(client *http.Client, method string, url string, payload []byte, options ...request.Options) (result Response, returnErr error) {
 	// If no request.Options was passed through, create a default instance
 	var opt RequestOptions
 	if len(options) == 0 {
@@ -41,128 +51,747 @@ func doRequest(client *http.Client, method string, url string, payload []byte, o
 	} else {
 		opt = options[0]
 	}
+	start := opt.Now()
+
+	// A request with a token provider is retried once with a new token if it is rejected
+	if opt.TokenProvider != nil {
+		return doRequestWithToken(client, method, url, payload, opt)
+	}
+
+	// The final response is reported once everything else has been released
+	complete, err := requestComplete(opt)
+	if err != nil {
+		return Response{}, err
+	}
+	if complete != nil {
+		defer func() { complete(result, returnErr) }()
+	}
 
 	// Check if there is a pre-defined protocol scheme, else default to https://
-	url, err := normaliseURL(url, opt.ProtocolScheme)
+	url, err = checkURL(url, opt)
+	if err != nil {
+		return response.Response{}, err
+	}
+	resumeFrom := prepareHeaders(&opt)
+
+	// build the initial Response object
+	response := response.New(url, method, payload, opt)
+
+	// The http.Client copies the X-TraceID on to each redirect
+	if response.UniqueIdentifier != "" && !opt.HasHeader("X-TraceID") {
+		opt.AddHeader("X-TraceID", response.UniqueIdentifier)
+	}
+
+	// A streamed response releases the resources of the request when it is closed
+	var release releaser
+	defer func() {
+		if response.Stream == nil {
+			release.run()
+		}
+	}()
+
+	body, stream, err := requestBody(&opt, payload, &release)
+	if err != nil {
+		return response, err
+	}
+
+	// Configure a copy of the client so any per request settings do not leak in to other requests
+	client, err = configureClient(client, opt)
+	if err != nil {
+		response.Error = err
+		return response, err
+	}
+	if len(opt.RedirectCodes) > 0 && !opt.DisableRedirect {
+		client.Transport = &redirectTransport{base: client.Transport, codes: opt.RedirectCodes}
+	}
+	client.CheckRedirect = checkRedirect(opt, &response, complete)
+
+	// The client Timeout still applies, whichever is reached first wins
+	ctx := context.Background()
+	if opt.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.Timeout)
+		release.add(cancel)
+	}
+	ctx, phase, sent := clientTrace(ctx, opt.Now, &response)
+
+	// ready the request
+	request, err := newRequest(ctx, method, url, body, stream, opt)
+	if err != nil {
+		response.Error = err
+		return response, err
+	}
+
+	// Perform the actual request
+	response.RequestTime = opt.Now().Unix()
+	if err = interceptRequest(request, opt); err != nil {
+		// Closing the body stops a body stream waiting to be read
+		if request.Body != nil {
+			request.Body.Close()
+		}
+		response.Error = err
+		return response, err
+	}
+	opt.Log(slog.LevelDebug, "sending request", "id", response.UniqueIdentifier, "method", method, "url", url)
+	if opt.OnRequestStart != nil {
+		opt.OnRequestStart(request.Method, request.URL.String())
+	}
+	r, err := client.Do(request)
 	if err != nil {
-		return response.Response{}, fmt.Errorf("supplied url did not pass url.Parse(): %w", err)
+		err = phase.wrap(err)
+		opt.Log(slog.LevelError, "request failed", "id", response.UniqueIdentifier, "error", err)
+		response.Error = err
+		return response, err
+	}
+	release.add(func() { r.Body.Close() })
+	response.ResponseTime = opt.Now().Unix()
+
+	err = readResponse(ctx, client, r, opt, &response, &release, resumeFrom)
+
+	// request has completed, add details to the response object
+	response.PopulateResponse(r, request.URL, start)
+	// The headers written on the wire include those added by the transport, i.e.: Accept-Encoding
+	if header := sent.header(); header != nil {
+		response.SentHeaders = header
+	}
+	if err != nil {
+		response.Error = err
+		return response, err
+	}
+	if response.Stream != nil {
+		opt.Log(slog.LevelDebug, "streaming response", "id", response.UniqueIdentifier, "status", response.StatusCode)
+		return response, nil
 	}
+	opt.Log(slog.LevelDebug, "request completed", "id", response.UniqueIdentifier, "status", response.StatusCode, "duration", response.AccessTime)
+
+	return response, nil
+}
 
+// prepareHeaders adds the headers implied by the RequestOptions. It returns the offset a partially
+// downloaded ResumeFile continues from.
+func prepareHeaders(opt *RequestOptions) int64 {
 	// Adjust the UserAgent
 	if opt.UserAgent == "" {
 		opt.UserAgent = useragent
 	}
 	opt.AddHeader("User-Agent", opt.UserAgent)
 
-	// build the initial Response object
-	response := response.New(url, method, payload, opt)
+	if opt.IdempotencyKey != "" {
+		opt.AddHeader("Idempotency-Key", opt.IdempotencyKey)
+	}
+
+	// Ask for the rest of a partially downloaded file
+	var resumeFrom int64
+	if opt.ResumeFile != "" {
+		resumeFrom = resumeOffset(opt.ResumeFile)
+		if resumeFrom > 0 && !opt.HasHeader("Range") {
+			opt.AddHeader("Range", fmt.Sprintf("bytes=%d-", resumeFrom))
+		}
+	}
+
+	// The transport only hands back the raw body when it did not ask for compression itself
+	if (opt.CompressedCopy != "" || opt.WireProgress || opt.LenientDecoding || opt.DisableTransportCompression) && !opt.HasHeader("Accept-Encoding") {
+		opt.AddHeader("Accept-Encoding", string(request.CompressionGzip))
+	}
+	return resumeFrom
+}
+
+// requestBody returns the request body from the payload or the body stream of the RequestOptions,
+// compressed as required. A body stream is also returned so it can be replayed.
+func requestBody(opt *RequestOptions, payload []byte, release *releaser) (io.Reader, *bodyStream, error) {
+	// Only a request which carries a body needs a Content-Type
+	hasBody := opt.BodyStream != nil || opt.BodyReaderAt != nil || len(payload) > 0
+	if hasBody && opt.DefaultContentType != "" && !opt.HasHeader("Content-Type") {
+		opt.AddHeader("Content-Type", opt.DefaultContentType)
+	}
+	if hasBody && opt.ExpectContinueTimeout > 0 && !opt.HasHeader("Expect") {
+		opt.AddHeader("Expect", "100-continue")
+	}
+
+	// A pre-compressed payload is sent as-is with only the Content-Encoding declared
+	if opt.PreCompressed != request.CompressionNone && hasBody {
+		opt.Compression = request.CompressionNone
+		opt.AddHeader("Content-Encoding", string(opt.PreCompressed))
+	}
+	// Compression and transforms are writers, so the body is written through them as a body stream
+	if opt.BodyReaderAt != nil && (opt.Compression != request.CompressionNone || len(opt.RequestTransforms) > 0) {
+		section := io.NewSectionReader(opt.BodyReaderAt, 0, opt.BodyReaderAtSize)
+		opt.BodyStream = func(w io.Writer) error {
+			_, err := io.Copy(w, section)
+			return err
+		}
+	}
+	if opt.BodyStream == nil && opt.BodyReaderAt == nil && len(payload) > 0 && len(opt.RequestTransforms) > 0 {
+		opt.BodyStream = func(w io.Writer) error {
+			_, err := w.Write(payload)
+			return err
+		}
+	}
+
+	// A body stream takes priority over the payload
+	if opt.BodyStream != nil {
+		stream := newBodyStream(*opt)
+		release.add(stream.stop)
+		if opt.Compression != request.CompressionNone {
+			opt.AddHeader("Content-Encoding", string(opt.Compression))
+		}
+		return stream.pipe, stream, nil
+	}
+	if opt.BodyReaderAt != nil {
+		return io.NewSectionReader(opt.BodyReaderAt, 0, opt.BodyReaderAtSize), nil, nil
+	}
+	if len(payload) == 0 {
+		return nil, nil, nil
+	}
+	if opt.Compression == request.CompressionNone {
+		return bytes.NewBuffer(payload), nil, nil
+	}
 
-	var requestPayload io.Reader
-	// Assuming there is a payload, check the options to see if compression is required
 	// Apply the compression to the payload and set the appropriate header to inform
 	// the server it is receiving compressed data
-	if len(payload) > 0 {
-		if opt.Compression != request.CompressionNone {
-			var cbody bytes.Buffer
-			var writer io.WriteCloser
-			switch opt.Compression {
-			case request.CompressionGzip:
-				writer = gzip.NewWriter(&cbody)
-			case request.CompressionDeflate:
-				writer = zlib.NewWriter(&cbody)
-			case request.CompressionBrotli:
-				writer = brotli.NewWriter(&cbody)
-			default:
-				return response, fmt.Errorf("unsupported compression type: %s", opt.Compression)
+	var cbody bytes.Buffer
+	writer, err := request.GetCompressorLevel(opt.Compression, opt.CompressionLevel, &cbody)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = writer.Write(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	writer.Close()
+	opt.AddHeader("Content-Encoding", string(opt.Compression))
+	return &cbody, nil, nil
+}
+
+// newRequest readies the request with the body, headers and cookies of the RequestOptions.
+func newRequest(ctx context.Context, method string, url string, body io.Reader, stream *bodyStream, opt RequestOptions) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// The http.Client only knows the length of, and how to replay, a body from a bytes.Buffer,
+	// bytes.Reader or strings.Reader
+	if section, ok := body.(*io.SectionReader); ok {
+		req.ContentLength = section.Size()
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(opt.BodyReaderAt, 0, opt.BodyReaderAtSize)), nil
+		}
+	}
+	if stream != nil && opt.BodyRewind != nil {
+		req.GetBody = stream.replay
+	}
+
+	// Throttle the body, including when it is replayed for a redirect
+	if opt.UploadRateLimit > 0 && req.Body != nil {
+		req.Body = newThrottledReader(ctx, req.Body, opt.UploadRateLimit)
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return newThrottledReader(ctx, body, opt.UploadRateLimit), nil
 			}
-			_, err := writer.Write(payload)
-			if err != nil {
-				return response, err
+		}
+	}
+
+	// A body stream reports progress as it is written, any other body as it is read by the transport
+	if opt.OnUploadProgress != nil && stream == nil && req.Body != nil {
+		total := req.ContentLength
+		req.Body = newUploadProgress(req.Body, total, opt.OnUploadProgress)
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return newUploadProgress(body, total, opt.OnUploadProgress), nil
 			}
-			writer.Close()
-			requestPayload = &cbody
-			opt.AddHeader("Content-Encoding", string(opt.Compression))
-		} else {
-			requestPayload = bytes.NewBuffer(payload)
 		}
 	}
 
-	// ready the request
-	request, err := http.NewRequest(method, url, requestPayload)
-	if err != nil {
-		response.Error = err
-		return response, err
+	// An unknown length makes the transport send the body chunked
+	if opt.ForceChunked && body != nil {
+		req.ContentLength = -1
 	}
 
 	// Assign headers from the RequestOptions
 	for _, v := range opt.Headers {
-		request.Header.Set(v.Key, v.Value)
+		req.Header.Set(v.Key, v.Value)
+	}
+	for _, v := range opt.HeaderFuncs {
+		req.Header.Set(v.Key, v.Value())
 	}
 
 	// Assign cookies from the RequestOptions
 	for _, v := range opt.Cookies {
-		request.AddCookie(v)
+		req.AddCookie(v)
 	}
+	return req, nil
+}
 
-	// Configure the HTTP client to follow or not follow redirects
-	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		if opt.DisableRedirect {
-			return http.ErrUseLastResponse
+// readResponse reads the body of the response in to the Response, or the Writer of the RequestOptions.
+// With StreamOutput the body is handed to the caller instead.
+func readResponse(ctx context.Context, client *http.Client, r *http.Response, opt RequestOptions, resp *Response, release *releaser, resumeFrom int64) (err error) {
+	if err = interceptResponse(r, opt); err != nil {
+		return err
+	}
+	if opt.ExpectedRedirect != "" {
+		if err = checkRedirectTarget(r, opt.ExpectedRedirect); err != nil {
+			return err
 		}
-		return nil
 	}
 
 	// To prevent out of memory if a very large payload is provided we can stream the bytes to a file
 	// or any data structure that implements the io.Writer interface.
 	// This is set in request.Options Writer
-	var writer io.Writer = &response.Body
+	var writer io.Writer = &resp.Body
+	var spill *spillWriter
 	if opt.Writer != nil {
 		writer = opt.Writer
+	} else if opt.SpillThreshold > 0 {
+		spill = &spillWriter{buf: &resp.Body, threshold: opt.SpillThreshold, dir: opt.SpillDir}
+		writer = spill
 	}
 
-	var r *http.Response
-	// Perform the actual request
-	response.RequestTime = time.Now().Unix()
-	r, err = client.Do(request)
+	// A resumed download is written to its file, unless the server did not send the body
+	var resume *resumeState
+	if opt.ResumeFile != "" {
+		f, state, err := openResumeFile(r, opt.ResumeFile, resumeFrom)
+		if err != nil {
+			return err
+		}
+		if f != nil {
+			release.add(func() { f.Close() })
+			writer, spill, resume = f, nil, state
+		}
+	}
+
+	// The temporary file is only kept for the caller when the request succeeds
+	if spill != nil {
+		defer func() {
+			if err != nil {
+				spill.discard()
+			}
+		}()
+	}
 
+	body, wire, err := decodeBody(ctx, client, r, opt, resume, release)
 	if err != nil {
-		response.Error = err
-		return response, err
+		return err
+	}
+
+	// Hand the body to the caller rather than reading it
+	if opt.StreamOutput {
+		resp.Stream = &streamBody{reader: body, release: *release}
+		return nil
+	}
+
+	if err = copyBody(r, body, writer, wire, resume, opt, resp); err != nil {
+		return err
+	}
+	resp.ProcessedTime = opt.Now().Unix()
+
+	// Check if the writer implements io.Closer and close it if so
+	if closer, ok := writer.(io.Closer); ok {
+		if err = closer.Close(); err != nil {
+			return err
+		}
+	}
+
+	if spill != nil {
+		resp.SpillFile = spill.Name()
+	}
+	return nil
+}
+
+// decodeBody returns the response body decompressed and transformed as set in the RequestOptions,
+// along with the count of the bytes received on the wire.
+func decodeBody(ctx context.Context, client *http.Client, r *http.Response, opt RequestOptions, resume *resumeState, release *releaser) (io.Reader, *countingReader, error) {
+	// Continue the body with a Range request if the connection fails part way
+	if opt.AutoResume {
+		if rr := newResumingReader(client, r); rr != nil {
+			r.Body = rr
+		}
+	}
+
+	var received io.Reader = r.Body
+	if opt.DownloadRateLimit > 0 {
+		received = newThrottledReader(ctx, r.Body, opt.DownloadRateLimit)
+	}
+	wire := &countingReader{reader: received}
+	var body io.Reader = wire
+
+	// Keep a copy of the body as it was received, before it is decompressed
+	if opt.CompressedCopy != "" {
+		f, err := os.Create(opt.CompressedCopy)
+		if err != nil {
+			return nil, nil, err
+		}
+		release.add(func() { f.Close() })
+		body = io.TeeReader(body, f)
+	}
+
+	if opt.OnDownloadProgress != nil && opt.WireProgress {
+		body = downloadProgress(body, r.ContentLength, resume, opt)
+	}
+
+	// Decompress the body if the transport has not already done so
+	uncompressed := r.Uncompressed
+	body, err := decompressBody(r, body, opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The decompressed size is only known when the body was not compressed on the wire
+	if opt.OnDownloadProgress != nil && !opt.WireProgress {
+		total := r.ContentLength
+		if r.Uncompressed != uncompressed {
+			total = -1
+		}
+		body = downloadProgress(body, total, resume, opt)
+	}
+
+	for _, transform := range opt.ResponseTransforms {
+		if body, err = transform(body); err != nil {
+			return nil, nil, err
+		}
+		if closer, ok := body.(io.Closer); ok {
+			release.add(func() { closer.Close() })
+		}
+	}
+
+	// Validate the content type before anything is written
+	if opt.RequireJSON {
+		if err = checkJSON(r, body); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if opt.MaxResponseSize > 0 {
+		body = &maxSizeReader{reader: body, remaining: opt.MaxResponseSize}
+	}
+	return body, wire, nil
+}
+
+// copyBody reads the decoded body in to the writer, checking it against the response as set in
+// the RequestOptions.
+func copyBody(r *http.Response, body io.Reader, writer io.Writer, wire *countingReader, resume *resumeState, opt RequestOptions, resp *Response) error {
+	if opt.ValidateUTF8 && opt.Writer == nil && resume == nil {
+		body = &utf8Reader{reader: body}
+	}
+
+	var digest hash.Hash
+	if opt.ChecksumTrailer != "" || opt.ChecksumHeader != "" {
+		var err error
+		digest, err = newHash(opt.ChecksumAlgorithm)
+		if err != nil {
+			return err
+		}
+		body = io.TeeReader(body, digest)
+	}
+
+	// Closing the unread rest of the body closes the connection
+	var limited *io.LimitedReader
+	if opt.MaxDownloadBytes > 0 {
+		limited = &io.LimitedReader{R: body, N: opt.MaxDownloadBytes}
+		body = limited
 	}
-	defer r.Body.Close()
-	response.ResponseTime = time.Now().Unix()
 
 	// convert the http.Response.Body to a bytes.Buffer
 	// bytes.Buffer was a preferred choice because I found it to be more flexible than
 	// returning []byte
-	_, err = io.Copy(writer, r.Body)
+	var out io.Writer = writer
+	encoder, err := transformEncoder(r, writer, opt)
 	if err != nil {
-		response.Error = err
-		return response, err
+		return err
+	}
+	if encoder != nil {
+		out = encoder
+	}
+	resp.DecodedLength, err = io.Copy(out, body)
+	if err == nil && encoder != nil {
+		err = encoder.Close()
+	}
+	if err == nil && limited != nil && limited.N == 0 {
+		// The body was only truncated if there is more to read
+		_, probe := io.ReadFull(limited.R, make([]byte, 1))
+		resp.Truncated = probe == nil
+	}
+	if opt.StrictContentLength && !resp.Truncated {
+		err = checkContentLength(r, wire.n, err)
 	}
-	response.ProcessedTime = time.Now().Unix()
+	if err == nil && opt.ChecksumHeader != "" && !resp.Truncated {
+		err = verifyChecksum(r.Header.Get(opt.ChecksumHeader), opt.ChecksumAlgorithm, digest.Sum(nil))
+	}
+	if err == nil && opt.ChecksumTrailer != "" && !resp.Truncated {
+		// Trailers are only available once the body has been read to the end
+		err = verifyChecksum(r.Trailer.Get(opt.ChecksumTrailer), opt.ChecksumAlgorithm, digest.Sum(nil))
+	}
+	return err
+}
 
-	if opt.Writer != nil {
-		err = writer.(io.Closer).Close()
-		if err != nil {
-			response.Error = err
-			return response, err
+// downloadProgress wraps the body to report the download progress. A resumed download reports
+// against the whole file rather than the part being received.
+func downloadProgress(body io.Reader, total int64, resume *resumeState, opt RequestOptions) io.Reader {
+	progress := newProgressReader(body, total, opt.OnDownloadProgress)
+	if resume != nil {
+		progress.bytesRead, progress.totalBytes = resume.start, resume.total
+	}
+	return progress
+}
+
+// transformEncoder returns the compressor which re-encodes the decoded body as it is written to w, when
+// RequestOptions.TransformEncoding applies to the response. Otherwise it returns nil.
+func transformEncoder(r *http.Response, w io.Writer, opt RequestOptions) (io.WriteCloser, error) {
+	if opt.TransformTo == request.CompressionNone || !r.Uncompressed || response.Encoding(r) != opt.TransformFrom {
+		return nil, nil
+	}
+	return request.GetCompressor(opt.TransformTo, w)
+}
+
+// requestComplete returns the OnRequestComplete callback of the RequestOptions, or nil if none is set.
+func requestComplete(opt RequestOptions) (func(Response, error), error) {
+	switch fn := opt.OnRequestComplete.(type) {
+	case nil:
+		return nil, nil
+	case func(Response, error):
+		return fn, nil
+	default:
+		return nil, fmt.Errorf("OnRequestComplete must be a func(response.Response, error), got %T", fn)
+	}
+}
+
+// redirectResponse returns the Response of a redirect which was followed.
+func redirectResponse(resp *http.Response) Response {
+	return response.Redirect(resp)
+}
+
+// interceptRequest calls the request interceptors in order, stopping at the first error.
+func interceptRequest(req *http.Request, opt RequestOptions) error {
+	for _, fn := range opt.RequestInterceptors {
+		if err := fn(req); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// Check if the writer implements io.Closer and close it if so
-	if closer, ok := writer.(io.Closer); ok {
-		err = closer.Close()
-		if err != nil {
-			response.Error = err
-			return response, err
+// interceptResponse calls the response interceptors in order, stopping at the first error.
+func interceptResponse(resp *http.Response, opt RequestOptions) error {
+	for _, fn := range opt.ResponseInterceptors {
+		if err := fn(resp); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// request has completed, add details to the response object
-	response.PopulateResponse(r, start)
+// checkRedirectTarget returns a *response.RedirectError unless the first redirect target or the final URL
+// of the response matches the expected URL. A * in the expected URL matches any characters.
+func checkRedirectTarget(r *http.Response, expected string) error {
+	final := r.Request.URL.String()
+	if r.Request.Response == nil {
+		return &response.RedirectError{Expected: expected, Final: final}
+	}
+	// Each redirected request links to the response which caused it, so walk back to the first hop
+	first := r.Request
+	for first.Response.Request.Response != nil {
+		first = first.Response.Request
+	}
+	if matchWildcard(expected, first.URL.String()) || matchWildcard(expected, final) {
+		return nil
+	}
+	return &response.RedirectError{Expected: expected, First: first.URL.String(), Final: final}
+}
 
-	return response, nil
+// matchWildcard reports if s matches the pattern, where each * matches any sequence of characters.
+func matchWildcard(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
+
+// bodyHeaders are the headers describing a request body, which the http.Client removes
+// when a redirect drops the body.
+var bodyHeaders = []string{"Content-Encoding", "Content-Language", "Content-Length", "Content-Location", "Content-Type"}
+
+// preserveMethod restores the method and body of the original request when following a 301 or 302 redirect.
+// The http.Client already keeps them for a 307 or 308, and a 303 always switches to GET.
+// A body which cannot be replayed, such as a body stream, returns an error.
+func preserveMethod(req *http.Request, via []*http.Request) error {
+	orig := via[0]
+	if orig.Method == http.MethodGet || orig.Method == http.MethodHead || req.Response == nil {
+		return nil
+	}
+	switch req.Response.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound:
+		req.Method = orig.Method
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		// A previous 301 or 302 hop may have dropped the body for the rest of the chain
+		if req.Body != nil && req.Body != http.NoBody {
+			return nil
+		}
+	default:
+		return nil
+	}
+	if orig.GetBody == nil {
+		// A body stream has an unknown length, so check for the body itself
+		if orig.Body != nil && orig.Body != http.NoBody {
+			return fmt.Errorf("cannot preserve the %s body on redirect as it cannot be replayed, use a []byte payload instead", orig.Method)
+		}
+		return nil
+	}
+	body, err := orig.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	req.GetBody = orig.GetBody
+	req.ContentLength = orig.ContentLength
+	for _, key := range bodyHeaders {
+		if values := orig.Header.Values(key); len(values) > 0 {
+			req.Header[key] = values
+		}
+	}
+	return nil
+}
+
+// decompressBody wraps body with a decompressor matching the Content-Encoding of the response.
+// If the transport has already decompressed the response, the body is empty, or the encoding is not
+// one the client supports, body is returned unchanged.
+// With LenientDecoding, a body which is not actually compressed as declared is returned raw.
+func decompressBody(r *http.Response, body io.Reader, opt RequestOptions) (io.Reader, error) {
+	encoding := request.CompressionType(r.Header.Get("Content-Encoding"))
+	if r.Uncompressed || !request.IsSupported(encoding) {
+		return body, nil
+	}
+
+	// Wait for the first byte only, so a streamed body is not held back until more has arrived
+	buffered := bufio.NewReaderSize(body, decompressionPrefix)
+	if _, err := buffered.Peek(1); err != nil {
+		// An empty body, i.e.: the response to a HEAD request, has nothing to decompress
+		if err == io.EOF {
+			return buffered, nil
+		}
+		return nil, err
+	}
+	// Only the part of the body which has already arrived is inspected
+	if opt.LenientDecoding {
+		prefix, _ := buffered.Peek(buffered.Buffered())
+		if !isCompressed(encoding, prefix) {
+			opt.Log(slog.LevelWarn, "response body is not compressed as declared, using the raw body", "encoding", encoding)
+			return buffered, nil
+		}
+	}
+
+	body, err := request.GetDecompressor(encoding, buffered)
+	if err != nil {
+		return nil, err
+	}
+	r.Uncompressed = true
+	return body, nil
+}
+
+// decompressionPrefix is the most bytes inspected before a response body is decompressed
+const decompressionPrefix = 512
+
+// isCompressed reports if the prefix of a body can be decompressed with the given encoding.
+// Running out of data is expected as only a prefix is available, any other error means the
+// body is not in the declared format.
+func isCompressed(encoding request.CompressionType, prefix []byte) bool {
+	dec, err := request.GetDecompressor(encoding, bytes.NewReader(prefix))
+	if err == nil {
+		_, err = dec.Read(make([]byte, decompressionPrefix))
+	}
+	return err == nil || err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// checkContentLength returns a *response.ContentLengthError if the number of bytes read from the wire
+// is less than the Content-Length of the response. The transport never reads past the Content-Length,
+// so only a short body can be detected. Chunked responses, or those without a Content-Length, are not
+// checked. The transport reports a short body as an unexpected EOF.
+func checkContentLength(r *http.Response, read int64, err error) error {
+	if r.ContentLength < 0 || len(r.TransferEncoding) > 0 {
+		return err
+	}
+	if (err == nil || errors.Is(err, io.ErrUnexpectedEOF)) && read < r.ContentLength {
+		return &response.ContentLengthError{Expected: r.ContentLength, Actual: read}
+	}
+	return err
+}
+
+// checkJSON returns a *response.ContentTypeError if the response has a body which is not JSON.
+// Both application/json and structured syntax suffixes such as application/problem+json are accepted.
+func checkJSON(r *http.Response, body io.Reader) error {
+	if r.StatusCode == http.StatusNoContent || r.ContentLength == 0 {
+		return nil
+	}
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(body, 256))
+	return &response.ContentTypeError{Expected: "application/json", ContentType: contentType, Snippet: string(snippet)}
+}
+
+// streamPayload runs the RequestOptions.BodyStream function in a goroutine which writes in to an io.Pipe.
+// The read side of the pipe is used as the request body so the payload is never fully buffered.
+// If compression is required the stream is compressed on the fly.
+// An error returned from the BodyStream closes the pipe with the error, aborting the request.
+// The returned channel is closed once the BodyStream has returned.
+func streamPayload(opt RequestOptions) (*io.PipeReader, <-chan struct{}) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var w io.Writer = pw
+		// The writers are closed in the order the body passes through them
+		var closers []io.WriteCloser
+		if opt.Compression != request.CompressionNone {
+			compressor, err := request.GetCompressorLevel(opt.Compression, opt.CompressionLevel, pw)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			w = compressor
+			closers = append(closers, compressor)
+		}
+		// The first transform added is the first the body is written to
+		for i := len(opt.RequestTransforms) - 1; i >= 0; i-- {
+			transform := opt.RequestTransforms[i](w)
+			w = transform
+			closers = append([]io.WriteCloser{transform}, closers...)
+		}
+		// Progress is reported against the bytes written by the BodyStream, before any transform or compression
+		if opt.OnUploadProgress != nil {
+			w = &progressWriter{writer: w, totalBytes: -1, onProgress: opt.OnUploadProgress}
+		}
+		err := opt.BodyStream(w)
+		for _, closer := range closers {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, done
 }
 
 // Get performs an HTTP GET to the specified URL.
@@ -198,6 +827,46 @@ func FormPost(url string, payload map[string]string, opt ...RequestOptions) (Res
 	return doRequest(client, http.MethodPost, url, form.Encode(payload), opt...)
 }
 
+// MultipartUpload performs an HTTP POST as a multipart/form-data payload to the specified URL.
+// It accepts the URL string as its first argument and a map[string]any as the payload.
+// Values of type string are sent as form fields, while values of type *os.File and form.FilePart are
+// streamed from disk as files. Use RequestOptions.OnFileUploadProgress to follow the progress of each file.
+// Optionally, you can provide additional RequestOptions to customize the request.
+// Returns the HTTP response and an error if any.
+func MultipartUpload(url string, payload map[string]any, opt ...RequestOptions) (Response, error) {
+	return doRequest(client, http.MethodPost, url, nil, multipartOptions(fieldParts(payload), opt...))
+}
+
+// MultipartUploadParts works as MultipartUpload but writes the parts in the order given,
+// i.e.: for a server which requires signature fields to be sent before the file.
+func MultipartUploadParts(url string, parts []form.Part, opt ...RequestOptions) (Response, error) {
+	return doRequest(client, http.MethodPost, url, nil, multipartOptions(parts, opt...))
+}
+
+// multipartOptions returns the RequestOptions for a multipart upload of the parts.
+func multipartOptions(parts []form.Part, opt ...RequestOptions) RequestOptions {
+	var option RequestOptions
+	if len(opt) == 0 {
+		option = request.NewOptions()
+	} else {
+		option = opt[0]
+	}
+	contentType, body := form.MultipartParts(parts, option.OnFileUploadProgress)
+	option.AddHeader("Content-Type", contentType)
+	option.BodyStream = body
+	option.BodyRewind = nil
+	return option
+}
+
+// fieldParts returns the fields of a multipart payload as parts, in no particular order.
+func fieldParts(payload map[string]any) []form.Part {
+	parts := make([]form.Part, 0, len(payload))
+	for field, value := range payload {
+		parts = append(parts, form.Part{Field: field, Value: value})
+	}
+	return parts
+}
+
 // Put performs an HTTP PUT to the specified URL with the given payload.
 // It accepts the URL string as its first argument and the payload as the second argument.
 // Optionally, you can provide additional RequestOptions to customize the request.
@@ -243,7 +912,7 @@ func Head(url string, opt ...RequestOptions) (Response, error) {
 // Optionally, you can provide additional RequestOptions to customize the request.
 // Returns the HTTP response and an error if any.
 func Options(url string, opt ...RequestOptions) (Response, error) {
-	return doRequest(client, http.MethodHead, url, nil, opt...)
+	return doRequest(client, http.MethodOptions, url, nil, opt...)
 }
 
 // Trace performs an HTTP TRACE to the specified URL.
@@ -0,0 +1,400 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caelisco/http-client/request"
+	"github.com/caelisco/http-client/response"
+)
+
+func TestBodyStreamStoppedWhenRequestFails(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		opt := request.NewOptions()
+		opt.SetBodyFunc(func(w io.Writer) error {
+			_, err := w.Write([]byte("never sent"))
+			return err
+		})
+		// An invalid method fails when the request is built, after the stream has started
+		if _, err := Custom("BAD METHOD", "http://127.0.0.1", nil, opt); err == nil {
+			t.Fatal("expected an error for an invalid method")
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("body stream goroutines leaked: %d before, %d after", before, after)
+	}
+}
+
+func TestSetBodyReaderReplayedOnRedirect(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/start" {
+			http.Redirect(w, r, "/end", http.StatusTemporaryRedirect)
+			return
+		}
+		received = append(received, r.Method+" "+string(body))
+	}))
+	defer server.Close()
+
+	reader := strings.NewReader("skip:payload")
+	reader.Seek(5, io.SeekStart)
+	opt := request.NewOptions()
+	opt.SetBodyReader(reader)
+	if _, err := Post(server.URL+"/start", nil, opt); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0] != "POST payload" {
+		t.Fatalf("expected the body to be sent again from its offset, got %q", received)
+	}
+}
+
+func TestCompressedStreamIsNotHeldBack(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte("first\n"))
+		gz.Flush()
+		w.(http.Flusher).Flush()
+		// The second message is only sent once the client has received the first
+		select {
+		case <-release:
+		case <-time.After(2 * time.Second):
+		}
+		gz.Write([]byte("second\n"))
+		gz.Close()
+	}))
+	defer server.Close()
+
+	// Asking for gzip leaves the body to be decompressed by the client rather than the transport
+	opt := request.NewOptions()
+	opt.AddHeader("Accept-Encoding", "gzip")
+	var messages []string
+	start := time.Now()
+	err := Subscribe(server.URL, func(msg []byte) error {
+		if len(messages) == 0 {
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("first message was held back for %s", elapsed)
+			}
+			close(release)
+		}
+		messages = append(messages, string(msg))
+		return nil
+	}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(messages, ",") != "first,second" {
+		t.Fatalf("unexpected messages %q", messages)
+	}
+}
+
+func TestLenientDecompressionUsesRawBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte("not compressed"))
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.LenientDecompression()
+	resp, err := Get(server.URL, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "not compressed" {
+		t.Fatalf("expected the raw body, got %q", resp.String())
+	}
+}
+
+func TestEnforceContentLengthDetectsShortBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\nshort body")
+		buf.Flush()
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.EnforceContentLength()
+	_, err := Get(server.URL, opt)
+	var lengthErr *response.ContentLengthError
+	if !errors.As(err, &lengthErr) {
+		t.Fatalf("expected a *response.ContentLengthError, got %v", err)
+	}
+	if lengthErr.Expected != 100 || lengthErr.Actual != 10 {
+		t.Fatalf("expected 10 of 100 bytes, got %d of %d", lengthErr.Actual, lengthErr.Expected)
+	}
+}
+
+func TestSentHeadersIncludeTransportHeaders(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	resp, err := Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"Accept-Encoding", "User-Agent", "X-TraceID"} {
+		if got, want := resp.SentHeaders.Get(key), received.Get(key); got != want || got == "" {
+			t.Errorf("expected SentHeaders %s to be %q, got %q", key, want, got)
+		}
+	}
+}
+
+func TestForceChunkedUpload(t *testing.T) {
+	var chunked bool
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunked = len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "upload.txt")
+	if err := os.WriteFile(path, []byte("file content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	opt := request.NewOptions()
+	opt.SetReaderAtBody(f, 12)
+	if _, err = Post(server.URL, nil, opt); err != nil {
+		t.Fatal(err)
+	}
+	if chunked {
+		t.Fatal("expected a known size body to be sent with a Content-Length")
+	}
+
+	opt.ForceChunkedUpload()
+	if _, err = Post(server.URL, nil, opt); err != nil {
+		t.Fatal(err)
+	}
+	if !chunked || body != "file content" {
+		t.Fatalf("expected the body to be sent chunked when forced, got chunked=%t %q", chunked, body)
+	}
+}
+
+func TestBodyReaderOnMethodPreservingRedirect(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/start" {
+			http.Redirect(w, r, "/end", http.StatusFound)
+			return
+		}
+		received = r.Method + " " + string(body)
+	}))
+	defer server.Close()
+
+	payload := strings.Repeat("payload ", 64*1024)
+	opt := request.NewOptions()
+	opt.PreserveMethodOnRedirect = true
+	opt.SetBodyReader(strings.NewReader(payload))
+	if _, err := Post(server.URL+"/start", nil, opt); err != nil {
+		t.Fatal(err)
+	}
+	if received != "POST "+payload {
+		t.Fatalf("expected the full body to be sent again after the redirect, got %d bytes", len(received))
+	}
+
+	// A reader which is not an io.ReadSeeker cannot be sent again
+	received = ""
+	opt = request.NewOptions()
+	opt.PreserveMethodOnRedirect = true
+	opt.SetBodyReader(io.MultiReader(strings.NewReader(payload)))
+	if _, err := Post(server.URL+"/start", nil, opt); err == nil {
+		t.Fatal("expected an error for a body which cannot be replayed")
+	}
+	if received != "" {
+		t.Fatalf("expected the redirect not to be followed, got %q", received[:min(len(received), 20)])
+	}
+}
+
+func TestAddQueryParamMergesWithURLQuery(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.AddQueryParam("q", "a&b=c d")
+	opt.AddQueryParam("tag", "x")
+	opt.AddQueryParam("tag", "y")
+	if _, err := Get(server.URL+"/search?page=2", opt); err != nil {
+		t.Fatal(err)
+	}
+	if want := "page=2&q=a%26b%3Dc+d&tag=x&tag=y"; query != want {
+		t.Fatalf("expected query %q, got %q", want, query)
+	}
+}
+
+func TestExpectContinueHoldsCompressedBody(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only sends 100 Continue once the handler reads the body
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(gz)
+		received = r.Header.Get("Expect") + " " + string(body)
+	}))
+	defer server.Close()
+
+	written := make(chan error, 2)
+	opt := request.NewOptions()
+	opt.SetExpectContinueTimeout(5 * time.Second)
+	opt.Compress(request.CompressionGzip)
+	opt.SetBodyFunc(func(w io.Writer) error {
+		_, err := w.Write([]byte("upload"))
+		written <- err
+		return err
+	})
+	start := time.Now()
+	resp, err := Post(server.URL+"/reject", nil, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected the rejection without waiting for the timeout, took %s", elapsed)
+	}
+	// The compressed body blocks on the pipe until the transport reads it, which it never did
+	select {
+	case err = <-written:
+		if err == nil {
+			t.Fatal("expected the body not to be sent when the server rejected the headers")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the body stream to stop once the request completed")
+	}
+
+	if _, err = Post(server.URL+"/accept", nil, opt); err != nil {
+		t.Fatal(err)
+	}
+	if received != "100-continue upload" {
+		t.Fatalf("expected the body to be sent after 100 Continue, got %q", received)
+	}
+}
+
+func TestSetCompressionLevel(t *testing.T) {
+	var size int
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, _ := io.ReadAll(r.Body)
+		size = len(compressed)
+		gz, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(gz)
+		received = string(body)
+	}))
+	defer server.Close()
+
+	// Words picked from a small vocabulary compress better with a more thorough search
+	words := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel"}
+	random := rand.New(rand.NewSource(1))
+	var payload strings.Builder
+	for i := 0; i < 20000; i++ {
+		payload.WriteString(words[random.Intn(len(words))])
+		payload.WriteByte(' ')
+	}
+
+	sizes := make(map[int]int)
+	for _, level := range []int{1, 9} {
+		opt := request.NewOptions()
+		opt.Compress(request.CompressionGzip)
+		opt.SetCompressionLevel(level)
+		if _, err := Post(server.URL, []byte(payload.String()), opt); err != nil {
+			t.Fatal(err)
+		}
+		if received != payload.String() {
+			t.Fatalf("level %d: expected the body to decompress to the payload", level)
+		}
+		sizes[level] = size
+	}
+	if sizes[9] >= sizes[1] {
+		t.Fatalf("expected level 9 to send a smaller body than level 1, got %d and %d bytes", sizes[9], sizes[1])
+	}
+
+	opt := request.NewOptions()
+	opt.Compress(request.CompressionGzip)
+	opt.SetCompressionLevel(12)
+	if _, err := Post(server.URL, []byte(payload.String()), opt); !errors.Is(err, request.ErrInvalidCompression) {
+		t.Fatalf("expected ErrInvalidCompression for an invalid level, got %v", err)
+	}
+}
+
+func TestPipeUploadReportsUnknownTotal(t *testing.T) {
+	var chunked bool
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunked = r.ContentLength == -1 && len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < 3; i++ {
+			pw.Write([]byte("part "))
+		}
+		pw.Close()
+	}()
+	opt := request.NewOptions()
+	opt.SetBodyReader(pr)
+	var written []int64
+	opt.OnUploadProgress = func(bytesWritten, totalBytes int64) {
+		if totalBytes != -1 {
+			t.Errorf("expected a total of -1 for a body of unknown length, got %d", totalBytes)
+		}
+		written = append(written, bytesWritten)
+	}
+	if _, err := Put(server.URL, nil, opt); err != nil {
+		t.Fatal(err)
+	}
+	if !chunked || body != "part part part " {
+		t.Fatalf("expected the body to be sent chunked, got chunked=%t %q", chunked, body)
+	}
+	if len(written) == 0 || written[len(written)-1] != 15 {
+		t.Fatalf("expected progress to reach 15 bytes, got %v", written)
+	}
+}
@@ -8,3 +8,5 @@ require (
 )
 
 require github.com/oklog/ulid/v2 v2.1.0
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -1,8 +1,14 @@
 package client
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
 	netURL "net/url"
 	"strings"
+	"time"
 )
 
 func normaliseURL(url string, protocolScheme string) (string, error) {
@@ -31,3 +37,150 @@ func normaliseURL(url string, protocolScheme string) (string, error) {
 
 	return url, nil
 }
+
+// resolveBaseURL resolves a url without a scheme against the base. A query string on the base is kept
+// ahead of the query of the url.
+func resolveBaseURL(base *netURL.URL, url string) (string, error) {
+	ref, err := netURL.Parse(strings.TrimSpace(url))
+	if err != nil {
+		return "", fmt.Errorf("supplied url did not pass url.Parse(): %w", err)
+	}
+	if ref.IsAbs() {
+		return url, nil
+	}
+	resolved := base.ResolveReference(ref)
+	if base.RawQuery != "" && ref.RawQuery != base.RawQuery {
+		resolved.RawQuery = base.RawQuery
+		if ref.RawQuery != "" {
+			resolved.RawQuery += "&" + ref.RawQuery
+		}
+	}
+	return resolved.String(), nil
+}
+
+// checkURL normalises the url, adds the query parameters and checks it against the allowlist in the
+// RequestOptions.
+func checkURL(url string, opt RequestOptions) (string, error) {
+	url, err := normaliseURL(url, opt.ProtocolScheme)
+	if err != nil {
+		return "", fmt.Errorf("supplied url did not pass url.Parse(): %w", err)
+	}
+	u, err := netURL.Parse(url)
+	if err != nil {
+		return "", fmt.Errorf("supplied url did not pass url.Parse(): %w", err)
+	}
+	// The query parameters follow those already in the url, which are left as they were written
+	if len(opt.QueryParams) > 0 {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += opt.QueryParams.Encode()
+		url = u.String()
+	}
+	return url, opt.CheckURL(u)
+}
+
+// tlsSessionCache is shared by every request with TLSSessionCache set, so a session established by one
+// request can be resumed by the next even when they use different transports.
+var tlsSessionCache = tls.NewLRUClientSessionCache(0)
+
+// configureClient returns a copy of the *http.Client configured for a single request.
+// The original client is never modified so it can be safely shared between requests.
+// When the options require changes to the transport, a transport configured from the client's transport
+// is used. It is kept and shared by later requests with the same options, so connections are reused.
+func configureClient(client *http.Client, opt RequestOptions) (*http.Client, error) {
+	c := *client
+	if !customTransport(opt) {
+		return &c, nil
+	}
+	base, err := baseTransport(c.Transport)
+	if err != nil {
+		return nil, err
+	}
+	c.Transport = transports.get(newTransportKey(base, opt), func() *http.Transport {
+		return configureTransport(base, opt)
+	})
+	return &c, nil
+}
+
+// configureTransport returns a clone of base with the settings of the options applied.
+func configureTransport(base *http.Transport, opt RequestOptions) *http.Transport {
+	transport := base.Clone()
+	if opt.ConnectTimeout > 0 || opt.LocalAddr != nil {
+		// Match the dial timeout of the http.DefaultTransport unless one is set
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			LocalAddr: opt.LocalAddr,
+		}
+		if opt.ConnectTimeout > 0 {
+			dialer.Timeout = opt.ConnectTimeout
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	if customTLS(opt) {
+		// Clone keeps the existing TLS settings, which are only replaced when set in the options
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		configureTLS(transport.TLSClientConfig, opt)
+	}
+	if opt.DisableTransportCompression {
+		transport.DisableCompression = true
+	}
+	if opt.ExpectContinueTimeout > 0 {
+		transport.ExpectContinueTimeout = opt.ExpectContinueTimeout
+	}
+	if opt.Proxy != nil {
+		transport.Proxy = http.ProxyURL(opt.Proxy)
+	}
+	return transport
+}
+
+// customTransport reports if the options require a transport configured for the request.
+func customTransport(opt RequestOptions) bool {
+	return opt.ConnectTimeout > 0 || opt.LocalAddr != nil || opt.TLSServerName != "" || opt.DisableTransportCompression || opt.Proxy != nil ||
+		opt.ExpectContinueTimeout > 0 || customTLS(opt)
+}
+
+// customTLS reports if the options require changes to the TLS configuration of the transport.
+func customTLS(opt RequestOptions) bool {
+	return opt.TLSServerName != "" || opt.InsecureSkipVerify || len(opt.ClientCertificates) > 0 ||
+		opt.RootCAs != nil || opt.MinTLSVersion != 0 || opt.TLSSessionCache
+}
+
+// configureTLS applies the TLS settings of the options to config, which must belong to a cloned transport.
+func configureTLS(config *tls.Config, opt RequestOptions) {
+	if opt.TLSServerName != "" {
+		config.ServerName = opt.TLSServerName
+	}
+	if opt.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	if len(opt.ClientCertificates) > 0 {
+		config.Certificates = opt.ClientCertificates
+	}
+	if opt.RootCAs != nil {
+		config.RootCAs = opt.RootCAs
+	}
+	if opt.MinTLSVersion != 0 {
+		config.MinVersion = opt.MinTLSVersion
+	}
+	// A session cache already set on the transport is kept
+	if opt.TLSSessionCache && config.ClientSessionCache == nil {
+		config.ClientSessionCache = tlsSessionCache
+	}
+}
+
+// baseTransport returns the transport used by a client. A nil transport is the http.DefaultTransport.
+// Only an *http.Transport can be configured per request.
+func baseTransport(rt http.RoundTripper) (*http.Transport, error) {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, errors.New("the client transport is not an *http.Transport and cannot be configured per request")
+	}
+	return transport, nil
+}
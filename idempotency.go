@@ -0,0 +1,81 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// idempotencyCache keeps the responses of recently completed requests by their idempotency key,
+// and the requests still in flight so a duplicate waits for the first rather than being sent.
+type idempotencyCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	entries  map[string]idempotencyEntry
+	inflight map[string]*idempotencyCall
+}
+
+type idempotencyEntry struct {
+	response Response
+	expires  time.Time
+}
+
+// idempotencyCall is a request in flight. done is closed once the response and error are set.
+type idempotencyCall struct {
+	done     chan struct{}
+	response Response
+	err      error
+}
+
+// errIdempotentRequestPanicked is returned to the requests waiting on a request which panicked.
+var errIdempotentRequestPanicked = errors.New("request with the same idempotency key panicked")
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:      ttl,
+		entries:  make(map[string]idempotencyEntry),
+		inflight: make(map[string]*idempotencyCall),
+	}
+}
+
+// do returns the response kept for the key if it has not expired. If a request with the key is in flight,
+// it waits for and returns its result. Otherwise it sends the request and keeps a successful response.
+func (ic *idempotencyCache) do(key string, now func() time.Time, send func() (Response, error)) (Response, error) {
+	ic.mu.Lock()
+	ic.evict(now())
+	if entry, ok := ic.entries[key]; ok {
+		ic.mu.Unlock()
+		return entry.response, nil
+	}
+	if call, ok := ic.inflight[key]; ok {
+		ic.mu.Unlock()
+		<-call.done
+		return call.response, call.err
+	}
+	call := &idempotencyCall{done: make(chan struct{})}
+	ic.inflight[key] = call
+	ic.mu.Unlock()
+
+	// The waiting requests are released even if send panics
+	defer func() {
+		ic.mu.Lock()
+		delete(ic.inflight, key)
+		if call.err == nil {
+			ic.entries[key] = idempotencyEntry{response: call.response, expires: now().Add(ic.ttl)}
+		}
+		ic.mu.Unlock()
+		close(call.done)
+	}()
+	call.err = errIdempotentRequestPanicked
+	call.response, call.err = send()
+	return call.response, call.err
+}
+
+// evict removes the expired entries. The caller must hold the lock.
+func (ic *idempotencyCache) evict(now time.Time) {
+	for key, entry := range ic.entries {
+		if !now.Before(entry.expires) {
+			delete(ic.entries, key)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caelisco/http-client/request"
+)
+
+func TestIdempotencyCacheSendsConcurrentDuplicatesOnce(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Hold the request so the duplicates arrive while it is in flight
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(r.Header.Get("Idempotency-Key")))
+	}))
+	defer server.Close()
+
+	c := New()
+	c.EnableIdempotencyCache(time.Minute)
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			opt := request.NewOptions()
+			opt.IdempotencyKey = "order-1"
+			resp, err := c.Post(server.URL, []byte("order"), opt)
+			if err != nil {
+				t.Error(err)
+			}
+			bodies[i] = resp.String()
+		}(i)
+	}
+	wg.Wait()
+
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("expected 1 request to be sent, got %d", n)
+	}
+	for _, body := range bodies {
+		if body != "order-1" {
+			t.Fatalf("expected every caller to receive the first response, got %q", bodies)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/caelisco/http-client/request"
+	"github.com/caelisco/http-client/response"
+)
+
+// GetJSON performs an HTTP GET to the specified URL and decodes the JSON response in to a value of type T.
+// The request is made with the Client, or with the package level functions when c is nil.
+// A response with a status outside of the 2xx range returns a *response.StatusError rather than
+// attempting to decode an error page.
+// Optionally, you can provide additional RequestOptions to customize the request.
+// Returns the decoded value and an error if any.
+func GetJSON[T any](c *Client, url string, opt ...RequestOptions) (T, error) {
+	var v T
+	var resp Response
+	var err error
+	if c == nil {
+		resp, err = doRequest(client, http.MethodGet, url, nil, opt...)
+	} else {
+		resp, err = c.doRequest(http.MethodGet, url, nil, opt...)
+	}
+	if err != nil {
+		return v, err
+	}
+	if err = checkStatus(resp); err != nil {
+		return v, err
+	}
+	if err = json.Unmarshal(resp.Bytes(), &v); err != nil {
+		return v, fmt.Errorf("unable to decode JSON response from %s: %w", resp.URL, err)
+	}
+	return v, nil
+}
+
+// PostJSON performs an HTTP POST to the specified URL with v encoded as the JSON payload.
+// The Content-Type is set to application/json unless a Content-Type header has already been added.
+// Optionally, you can provide additional RequestOptions to customize the request.
+// Returns the HTTP response and an error if any.
+func PostJSON(url string, v any, opt ...RequestOptions) (Response, error) {
+	payload, option, err := jsonPayload(v, opt...)
+	if err != nil {
+		return Response{}, err
+	}
+	return doRequest(client, http.MethodPost, url, payload, option)
+}
+
+// PutJSON performs an HTTP PUT to the specified URL with v encoded as the JSON payload.
+// See PostJSON for how the Content-Type is set.
+func PutJSON(url string, v any, opt ...RequestOptions) (Response, error) {
+	payload, option, err := jsonPayload(v, opt...)
+	if err != nil {
+		return Response{}, err
+	}
+	return doRequest(client, http.MethodPut, url, payload, option)
+}
+
+// PatchJSON performs an HTTP PATCH to the specified URL with v encoded as the JSON payload.
+// See PostJSON for how the Content-Type is set.
+func PatchJSON(url string, v any, opt ...RequestOptions) (Response, error) {
+	payload, option, err := jsonPayload(v, opt...)
+	if err != nil {
+		return Response{}, err
+	}
+	return doRequest(client, http.MethodPatch, url, payload, option)
+}
+
+// PostJSON performs an HTTP POST to the specified URL with v encoded as the JSON payload.
+// See PostJSON for how the Content-Type is set.
+func (c *Client) PostJSON(url string, v any, opt ...RequestOptions) (Response, error) {
+	payload, option, err := jsonPayload(v, opt...)
+	if err != nil {
+		return Response{}, err
+	}
+	return c.doRequest(http.MethodPost, url, payload, option)
+}
+
+// PutJSON performs an HTTP PUT to the specified URL with v encoded as the JSON payload.
+// See PostJSON for how the Content-Type is set.
+func (c *Client) PutJSON(url string, v any, opt ...RequestOptions) (Response, error) {
+	payload, option, err := jsonPayload(v, opt...)
+	if err != nil {
+		return Response{}, err
+	}
+	return c.doRequest(http.MethodPut, url, payload, option)
+}
+
+// PatchJSON performs an HTTP PATCH to the specified URL with v encoded as the JSON payload.
+// See PostJSON for how the Content-Type is set.
+func (c *Client) PatchJSON(url string, v any, opt ...RequestOptions) (Response, error) {
+	payload, option, err := jsonPayload(v, opt...)
+	if err != nil {
+		return Response{}, err
+	}
+	return c.doRequest(http.MethodPatch, url, payload, option)
+}
+
+// jsonPayload encodes v as JSON and returns it with the RequestOptions for sending it.
+func jsonPayload(v any, opt ...RequestOptions) ([]byte, RequestOptions, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, RequestOptions{}, fmt.Errorf("unable to encode JSON payload: %w", err)
+	}
+	var option RequestOptions
+	if len(opt) == 0 {
+		option = request.NewOptions()
+	} else {
+		option = opt[0]
+	}
+	if !option.HasHeader("Content-Type") {
+		option.AddHeader("Content-Type", "application/json")
+	}
+	return payload, option, nil
+}
+
+// checkStatus returns a *response.StatusError if the status code of the response is not 2xx.
+// RFC 7807 problem details sent by the server are included in the error.
+func checkStatus(resp Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	snippet := resp.Bytes()
+	if len(snippet) > 256 {
+		snippet = snippet[:256]
+	}
+	statusErr := &response.StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Snippet: string(snippet)}
+	if problem, err := resp.ProblemDetails(); err == nil {
+		statusErr.Problem = problem
+	}
+	return statusErr
+}
@@ -4,3 +4,9 @@ type Header struct {
 	Key   string
 	Value string
 }
+
+// HeaderFunc is a header whose value is computed each time the request is sent.
+type HeaderFunc struct {
+	Key   string
+	Value func() string
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	netURL "net/url"
+	"strings"
+
+	"github.com/caelisco/http-client/request"
+)
+
+// Cursor extracts the URL of the next page from a response. It returns done as true when there are
+// no more pages. The next URL may be relative to the URL of the response.
+// Cursors can read the Link header, see LinkCursor, or a cursor in the response body.
+// Set it with RequestOptions.SetPaginationCursor.
+type Cursor func(resp Response) (nextURL string, done bool)
+
+// LinkCursor is a Cursor which follows the rel="next" URL of the Link header (RFC 8288).
+// It is done when there is no next link.
+func LinkCursor(resp Response) (string, bool) {
+	for _, value := range resp.Header.Values("Link") {
+		for _, link := range parseLinks(value) {
+			for _, rel := range strings.Fields(link.params["rel"]) {
+				if strings.EqualFold(rel, "next") {
+					return link.target, false
+				}
+			}
+		}
+	}
+	return "", true
+}
+
+// link is a link of a Link header, with the names of its parameters in lower case.
+type link struct {
+	target string
+	params map[string]string
+}
+
+// parseLinks parses the links of a Link header value. The target and quoted parameter values may contain
+// commas and semicolons, so the value is scanned rather than split. A malformed link ends the parsing.
+func parseLinks(value string) []link {
+	var links []link
+	for {
+		value = strings.TrimLeft(value, " \t,")
+		if !strings.HasPrefix(value, "<") {
+			return links
+		}
+		end := strings.IndexByte(value, '>')
+		if end < 0 {
+			return links
+		}
+		l := link{target: value[1:end], params: make(map[string]string)}
+		value = value[end+1:]
+		for {
+			value = strings.TrimLeft(value, " \t")
+			if !strings.HasPrefix(value, ";") {
+				break
+			}
+			value = strings.TrimLeft(value[1:], " \t")
+			i := strings.IndexAny(value, "=;,")
+			if i < 0 {
+				i = len(value)
+			}
+			name := strings.ToLower(strings.TrimSpace(value[:i]))
+			value = value[i:]
+			var param string
+			if strings.HasPrefix(value, "=") {
+				param, value = parseParamValue(strings.TrimLeft(value[1:], " \t"))
+			}
+			// The first occurrence of a parameter is used
+			if _, ok := l.params[name]; !ok && name != "" {
+				l.params[name] = param
+			}
+		}
+		links = append(links, l)
+	}
+}
+
+// parseParamValue returns a token or quoted string at the start of s, and the rest of s.
+func parseParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		i := strings.IndexAny(s, ";,")
+		if i < 0 {
+			i = len(s)
+		}
+		return strings.TrimSpace(s[:i]), s[i:]
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), ""
+}
+
+// GetAll performs an HTTP GET to the specified URL and follows the pages returned by the pagination cursor
+// of the RequestOptions until it reports done, or the Link header when no cursor is set.
+// The responses of every page are returned in order.
+// The context is checked between pages so a long pagination can be cancelled.
+// Optionally, you can provide additional RequestOptions to customize the requests.
+// Returns the pages fetched so far and an error if any.
+func GetAll(ctx context.Context, url string, opt ...RequestOptions) ([]Response, error) {
+	var option RequestOptions
+	if len(opt) > 0 {
+		option = opt[0]
+	}
+	cursor, err := paginationCursor(option)
+	if err != nil {
+		return nil, err
+	}
+	return paginate(ctx, url, cursor, func(url string) (Response, error) {
+		return doRequest(client, http.MethodGet, url, nil, opt...)
+	})
+}
+
+// paginationCursor returns the pagination cursor of the RequestOptions, or LinkCursor if none is set.
+func paginationCursor(opt request.Options) (Cursor, error) {
+	switch cursor := opt.PaginationCursor.(type) {
+	case nil:
+		return LinkCursor, nil
+	case Cursor:
+		return cursor, nil
+	case func(Response) (string, bool):
+		return cursor, nil
+	default:
+		return nil, fmt.Errorf("pagination cursor must be a func(response.Response) (string, bool), got %T", cursor)
+	}
+}
+
+// paginate fetches pages with get until the cursor is done or the context is cancelled.
+func paginate(ctx context.Context, url string, cursor Cursor, get func(url string) (Response, error)) ([]Response, error) {
+	var pages []Response
+	for {
+		if err := ctx.Err(); err != nil {
+			return pages, err
+		}
+		resp, err := get(url)
+		if err != nil {
+			return pages, err
+		}
+		pages = append(pages, resp)
+
+		next, done := cursor(resp)
+		if done || next == "" {
+			return pages, nil
+		}
+		url, err = resolveURL(resp.URL, next)
+		if err != nil {
+			return pages, err
+		}
+	}
+}
+
+// resolveURL resolves ref, which may be relative, against base.
+func resolveURL(base string, ref string) (string, error) {
+	b, err := netURL.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := netURL.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(r).String(), nil
+}
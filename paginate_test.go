@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caelisco/http-client/request"
+)
+
+func TestGetAllFollowsLinkHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "":
+			// The URL and a quoted parameter of the first link contain commas and semicolons
+			w.Header().Set("Link", `</items?page=a,b;c>; rel="prev"; title="a, b; c", </items?page=2>; rel="last next"`)
+		case "2":
+			w.Header().Set("Link", `</items?page=1>; rel=first`)
+		}
+		w.Write([]byte(r.URL.Query().Get("page")))
+	}))
+	defer server.Close()
+
+	pages, err := GetAll(context.Background(), server.URL+"/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 || pages[1].String() != "2" {
+		t.Fatalf("expected the next link to be followed once, got %d pages", len(pages))
+	}
+}
+
+func TestGetAllUsesCursorFromClientOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var page struct {
+			Items []int  `json:"items"`
+			Next  string `json:"next,omitempty"`
+		}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			page.Items, page.Next = []int{1, 2}, "abc"
+		case "abc":
+			page.Items, page.Next = []int{3, 4}, "def"
+		case "def":
+			page.Items = []int{5}
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	global := request.NewOptions()
+	global.SetPaginationCursor(func(resp Response) (string, bool) {
+		var page struct {
+			Next string `json:"next"`
+		}
+		if err := json.Unmarshal(resp.Bytes(), &page); err != nil || page.Next == "" {
+			return "", true
+		}
+		return "?cursor=" + page.Next, false
+	})
+	c := New(global)
+	pages, err := c.GetAll(context.Background(), server.URL+"/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []int
+	for _, page := range pages {
+		var body struct {
+			Items []int `json:"items"`
+		}
+		if err = json.Unmarshal(page.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, body.Items...)
+	}
+	if fmt.Sprint(items) != "[1 2 3 4 5]" {
+		t.Fatalf("expected the items of every page, got %v", items)
+	}
+
+	opt := request.NewOptions()
+	opt.SetPaginationCursor("next")
+	if _, err = GetAll(context.Background(), server.URL, opt); err == nil {
+		t.Fatal("expected an error for a cursor which is not a function")
+	}
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// phases are the phases of a request in the order they happen.
+// A reused connection skips the dns, connect and tls phases.
+var phases = []string{"dns", "connect", "tls", "send", "wait"}
+
+// PhaseError is returned when a request fails before a response is received. It records the last phase
+// of the request which completed and the time taken by each completed phase, so a timeout while connecting
+// can be told apart from one waiting for the response. With redirects, the phases are those of the last hop.
+type PhaseError struct {
+	Err       error                    // The error returned by the request
+	Phase     string                   // The last completed phase: dns, connect, tls, send or wait. Empty if none completed
+	Elapsed   time.Duration            // Time from the start of the request until it failed
+	Durations map[string]time.Duration // Time taken by each completed phase
+}
+
+func (e *PhaseError) Error() string {
+	var completed []string
+	for _, phase := range phases {
+		if d, ok := e.Durations[phase]; ok {
+			completed = append(completed, fmt.Sprintf("%s %s", phase, d))
+		}
+	}
+	if e.Phase == "" {
+		return fmt.Sprintf("request failed after %s before any phase completed: %v", e.Elapsed, e.Err)
+	}
+	return fmt.Sprintf("request failed after %s, last completed phase %s (%s): %v", e.Elapsed, e.Phase, strings.Join(completed, ", "), e.Err)
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+// phaseRecorder records the phases of a request from the httptrace callbacks, which may be called
+// from other goroutines.
+type phaseRecorder struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	start     time.Time
+	started   map[string]time.Time
+	phase     string
+	durations map[string]time.Duration
+}
+
+func newPhaseRecorder(now func() time.Time) *phaseRecorder {
+	return &phaseRecorder{now: now, start: now(), started: make(map[string]time.Time), durations: make(map[string]time.Duration)}
+}
+
+func (pr *phaseRecorder) begin(phase string) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.started[phase] = pr.now()
+}
+
+// done marks the phase as completed, unless it failed with err.
+func (pr *phaseRecorder) done(phase string, err error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if started, ok := pr.started[phase]; ok && err == nil {
+		pr.durations[phase] = pr.now().Sub(started)
+		pr.phase = phase
+	}
+}
+
+// duration returns the time taken by the phase, or zero if it has not completed.
+func (pr *phaseRecorder) duration(phase string) time.Duration {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	return pr.durations[phase]
+}
+
+// wrap returns err as a *PhaseError with the phases recorded so far.
+func (pr *phaseRecorder) wrap(err error) error {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	durations := make(map[string]time.Duration, len(pr.durations))
+	for phase, d := range pr.durations {
+		durations[phase] = d
+	}
+	return &PhaseError{Err: err, Phase: pr.phase, Elapsed: pr.now().Sub(pr.start), Durations: durations}
+}
+
+// headerRecorder records the header fields written for the latest request of a redirect chain.
+// The transport may write the request in another goroutine, so it is guarded by a lock.
+type headerRecorder struct {
+	mu     sync.Mutex
+	fields http.Header
+}
+
+// reset starts recording a new request.
+func (h *headerRecorder) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fields = http.Header{}
+}
+
+// add records a header field as it is written. HTTP/2 pseudo headers, such as :authority, are skipped.
+func (h *headerRecorder) add(key string, values []string) {
+	if strings.HasPrefix(key, ":") {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.fields == nil {
+		h.fields = http.Header{}
+	}
+	key = http.CanonicalHeaderKey(key)
+	h.fields[key] = append(h.fields[key], values...)
+}
+
+// header returns a copy of the recorded header, or nil if none was written.
+func (h *headerRecorder) header() http.Header {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.fields.Clone()
+}
+
+// clientTrace records the phases of the request and the headers written on the wire, along with
+// the time to first byte and TLS resumption of the Response. With redirects, the final hop is kept.
+func clientTrace(ctx context.Context, now func() time.Time, resp *Response) (context.Context, *phaseRecorder, *headerRecorder) {
+	phase := newPhaseRecorder(now)
+	sent := &headerRecorder{}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { phase.begin("dns") },
+		DNSDone:           func(info httptrace.DNSDoneInfo) { phase.done("dns", info.Err) },
+		ConnectStart:      func(string, string) { phase.begin("connect") },
+		ConnectDone:       func(_, _ string, err error) { phase.done("connect", err) },
+		TLSHandshakeStart: func() { phase.begin("tls") },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			phase.done("tls", err)
+			resp.TLSResumed = err == nil && state.DidResume
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			phase.begin("send")
+			sent.reset()
+		},
+		WroteHeaderField: sent.add,
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			phase.done("send", info.Err)
+			phase.begin("wait")
+		},
+		// The request is written and the response read in different goroutines
+		GotFirstResponseByte: func() {
+			phase.done("wait", nil)
+			resp.TimeToFirstByte = phase.duration("wait")
+		},
+	})
+	return ctx, phase, sent
+}
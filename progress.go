@@ -0,0 +1,132 @@
+package client
+
+import (
+	"io"
+	"unicode/utf8"
+
+	"github.com/caelisco/http-client/response"
+)
+
+// progressReader wraps an io.Reader and reports the bytes read so far to a callback.
+// A totalBytes of -1 means the total size is not known.
+type progressReader struct {
+	reader     io.Reader
+	bytesRead  int64
+	totalBytes int64
+	onProgress func(bytesRead, totalBytes int64)
+}
+
+func newProgressReader(r io.Reader, totalBytes int64, onProgress func(bytesRead, totalBytes int64)) *progressReader {
+	return &progressReader{
+		reader:     r,
+		totalBytes: totalBytes,
+		onProgress: onProgress,
+	}
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.reader.Read(p)
+	if n > 0 {
+		pr.bytesRead += int64(n)
+		pr.onProgress(pr.bytesRead, pr.totalBytes)
+	}
+	return n, err
+}
+
+// uploadProgress reports the progress of a request body as it is read, and closes the body when closed.
+type uploadProgress struct {
+	*progressReader
+	io.Closer
+}
+
+func newUploadProgress(body io.ReadCloser, totalBytes int64, onProgress func(bytesRead, totalBytes int64)) io.ReadCloser {
+	return uploadProgress{progressReader: newProgressReader(body, totalBytes, onProgress), Closer: body}
+}
+
+// countingReader counts the bytes read from the underlying io.Reader.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// progressWriter wraps an io.Writer and reports the bytes written so far to a callback.
+type progressWriter struct {
+	writer       io.Writer
+	bytesWritten int64
+	totalBytes   int64
+	onProgress   func(bytesWritten, totalBytes int64)
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.writer.Write(p)
+	if n > 0 {
+		pw.bytesWritten += int64(n)
+		pw.onProgress(pw.bytesWritten, pw.totalBytes)
+	}
+	return n, err
+}
+
+// maxSizeReader returns ErrResponseTooLarge once more than the maximum number of bytes would be read.
+// Only the bytes within the maximum are returned.
+type maxSizeReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (mr *maxSizeReader) Read(p []byte) (int, error) {
+	if mr.remaining < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	// Read one byte past the maximum to tell a body of exactly the maximum from a larger one
+	if int64(len(p)) > mr.remaining+1 {
+		p = p[:mr.remaining+1]
+	}
+	n, err := mr.reader.Read(p)
+	if int64(n) > mr.remaining {
+		n = int(mr.remaining)
+		mr.remaining = -1
+		return n, ErrResponseTooLarge
+	}
+	mr.remaining -= int64(n)
+	return n, err
+}
+
+// utf8Reader returns a *response.UTF8Error once an invalid UTF-8 sequence is read. A sequence split
+// across reads is kept until the rest of it has been read.
+type utf8Reader struct {
+	reader  io.Reader
+	offset  int64
+	pending []byte
+}
+
+func (ur *utf8Reader) Read(p []byte) (int, error) {
+	n, err := ur.reader.Read(p)
+	data := append(ur.pending, p[:n]...)
+	i := 0
+	for i < len(data) {
+		if data[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			break
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return n, &response.UTF8Error{Offset: ur.offset + int64(i)}
+		}
+		i += size
+	}
+	ur.offset += int64(i)
+	ur.pending = append(ur.pending[:0], data[i:]...)
+	if err == io.EOF && len(ur.pending) > 0 {
+		return n, &response.UTF8Error{Offset: ur.offset}
+	}
+	return n, err
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caelisco/http-client/request"
+)
+
+func TestTrackDownloadByWireBytesReaches100Percent(t *testing.T) {
+	content := strings.Repeat("compressible content ", 1000)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(content))
+	gz.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.Itoa(compressed.Len()))
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "download.txt")
+	opt := request.NewOptions()
+	if err := opt.FileWriter(path); err != nil {
+		t.Fatal(err)
+	}
+	opt.TrackDownloadByWireBytes()
+	var read, total int64
+	opt.OnDownloadProgress = func(bytesRead, totalBytes int64) {
+		read, total = bytesRead, totalBytes
+	}
+	if _, err := Get(server.URL, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	if total != int64(compressed.Len()) {
+		t.Fatalf("expected the total to be the compressed length %d, got %d", compressed.Len(), total)
+	}
+	if read != total {
+		t.Fatalf("expected the progress to reach 100%%, got %d of %d", read, total)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != content {
+		t.Fatalf("expected the file to hold the decompressed body, got %d bytes", len(written))
+	}
+}
+
+func TestSetMaxResponseSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.SetMaxResponseSize(1000)
+	resp, err := Get(server.URL, opt)
+	if err != nil {
+		t.Fatalf("expected a body at the maximum size to be read, got %v", err)
+	}
+	if resp.Length() != 1000 {
+		t.Fatalf("expected 1000 bytes, got %d", resp.Length())
+	}
+
+	opt.SetMaxResponseSize(999)
+	if _, err = Get(server.URL, opt); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge for a buffered body, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "download.txt")
+	opt = request.NewOptions()
+	if err = opt.FileWriter(path); err != nil {
+		t.Fatal(err)
+	}
+	opt.SetMaxResponseSize(100)
+	if _, err = Get(server.URL, opt); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge for a body written to a file, got %v", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > 100 {
+		t.Fatalf("expected at most 100 bytes to be written to the file, got %d", fi.Size())
+	}
+}
+
+func TestUploadProgressOfThrottledPayload(t *testing.T) {
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = len(body)
+	}))
+	defer server.Close()
+
+	payload := bytes.Repeat([]byte("x"), 4000)
+	opt := request.NewOptions()
+	opt.SetUploadRateLimit(8000)
+	var calls int
+	var read, total int64
+	opt.OnUploadProgress = func(bytesWritten, totalBytes int64) {
+		calls++
+		read, total = bytesWritten, totalBytes
+	}
+	start := time.Now()
+	if _, err := Post(server.URL, payload, opt); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Fatalf("expected the upload to be throttled, took %s", elapsed)
+	}
+	if received != 4000 || read != 4000 || total != 4000 {
+		t.Fatalf("expected progress to reach 4000 of 4000 bytes, got %d of %d with %d received", read, total, received)
+	}
+	// The body is read a tenth of a second of data at a time, so progress follows the throttled rate
+	if calls < 4 {
+		t.Fatalf("expected progress to be reported as the throttled body was sent, got %d calls", calls)
+	}
+}
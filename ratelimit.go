@@ -0,0 +1,49 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by all of the goroutines using a Client. Tokens are added at
+// rate per second up to burst, and each request takes one token.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rps, burst: float64(burst), tokens: float64(burst)}
+}
+
+// wait blocks until a token is available. The token is reserved before waiting, so concurrent
+// callers are released in turn rather than all at once when tokens are added.
+func (rl *rateLimiter) wait() {
+	if delay := rl.reserve(time.Now()); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// reserve takes a token and returns how long to wait until it is available.
+func (rl *rateLimiter) reserve(now time.Time) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if !rl.last.IsZero() {
+		rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+		if rl.tokens > rl.burst {
+			rl.tokens = rl.burst
+		}
+	}
+	rl.last = now
+	rl.tokens--
+	if rl.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+}
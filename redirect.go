@@ -0,0 +1,87 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// redirectTransport reports a response with one of the codes and a Location header as a 302 Found,
+// so it is followed by the http.Client under the same rules, limits and loop detection as any redirect.
+// The Status is rewritten with the code, so hooks and the redirect history see a consistent response.
+type redirectTransport struct {
+	base  http.RoundTripper
+	codes []int
+}
+
+func (rt *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := rt.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(rt.codes, resp.StatusCode) && resp.Header.Get("Location") != "" {
+		resp.StatusCode = http.StatusFound
+		resp.Status = "302 Found"
+	}
+	return resp, nil
+}
+
+// checkRedirect returns the CheckRedirect of the http.Client, which applies the RequestOptions to each hop
+// and records and reports the redirect responses.
+func checkRedirect(opt RequestOptions, resp *Response, complete func(Response, error)) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if opt.DisableRedirect {
+			return http.ErrUseLastResponse
+		}
+		// Replacing the default CheckRedirect removes its limit
+		limit := redirectLimit
+		if opt.MaxRedirects > 0 {
+			limit = opt.MaxRedirects
+		}
+		// via holds the original request and each redirect followed so far
+		if len(via) > limit {
+			return fmt.Errorf("%w: stopped after %d redirects", ErrMaxRedirects, limit)
+		}
+		for _, v := range via {
+			if v.Method == req.Method && v.URL.String() == req.URL.String() {
+				return fmt.Errorf("%w: %s %s", ErrRedirectLoop, req.Method, req.URL)
+			}
+		}
+		if err := opt.CheckURL(req.URL); err != nil {
+			return err
+		}
+		if opt.PreserveMethodOnRedirect {
+			if err := preserveMethod(req, via); err != nil {
+				return err
+			}
+		}
+		// Recompute dynamic headers so they are fresh for the next hop
+		for _, v := range opt.HeaderFuncs {
+			req.Header.Set(v.Key, v.Value())
+		}
+		// req.Response is the redirect response which caused this request
+		if req.Response != nil {
+			if err := interceptResponse(req.Response, opt); err != nil {
+				return err
+			}
+		}
+		if err := interceptRequest(req, opt); err != nil {
+			return err
+		}
+		if opt.RedirectHistory && req.Response != nil {
+			resp.AddRedirect(req.Response)
+		}
+		// The redirect completes before the request which follows it starts
+		if complete != nil && req.Response != nil {
+			complete(redirectResponse(req.Response), nil)
+		}
+		if opt.OnRequestStart != nil {
+			opt.OnRequestStart(req.Method, req.URL.String())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,244 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caelisco/http-client/request"
+	"github.com/caelisco/http-client/response"
+)
+
+func TestTraceIDStableAcrossRedirects(t *testing.T) {
+	var traceIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceIDs = append(traceIDs, r.Header.Get("X-TraceID"))
+		switch r.URL.Path {
+		case "/first":
+			http.Redirect(w, r, "/second", http.StatusFound)
+		case "/second":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	resp, err := Get(server.URL + "/first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traceIDs) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(traceIDs))
+	}
+	for _, id := range traceIDs {
+		if id == "" || id != resp.UniqueIdentifier {
+			t.Fatalf("expected every hop to send X-TraceID %q, got %q", resp.UniqueIdentifier, traceIDs)
+		}
+	}
+}
+
+func TestRedirectLoopIsDistinguishedFromLongChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop-a":
+			http.Redirect(w, r, "/loop-b", http.StatusFound)
+		case "/loop-b":
+			http.Redirect(w, r, "/loop-a", http.StatusFound)
+		case "/end":
+		default:
+			// /chain/n redirects to /chain/n-1 until /chain/0 reaches the end
+			var n int
+			fmt.Sscanf(r.URL.Path, "/chain/%d", &n)
+			if n == 0 {
+				http.Redirect(w, r, "/end", http.StatusFound)
+				return
+			}
+			http.Redirect(w, r, fmt.Sprintf("/chain/%d", n-1), http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	if _, err := Get(server.URL + "/loop-a"); !errors.Is(err, ErrRedirectLoop) {
+		t.Fatalf("expected ErrRedirectLoop, got %v", err)
+	}
+	resp, err := Get(server.URL + "/chain/7")
+	if err != nil {
+		t.Fatalf("expected a long chain within the limit to complete, got %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	_, err = Get(server.URL + "/chain/20")
+	if !errors.Is(err, ErrMaxRedirects) || errors.Is(err, ErrRedirectLoop) {
+		t.Fatalf("expected ErrMaxRedirects for a chain past the limit, got %v", err)
+	}
+}
+
+func TestRedirectMethodFollowsStatusCode(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var code int
+		if _, err := fmt.Sscanf(r.URL.Path, "/redirect/%d", &code); err == nil {
+			http.Redirect(w, r, "/end", code)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received = r.Method + " " + string(body)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		code     int
+		preserve bool
+		want     string
+	}{
+		{http.StatusMovedPermanently, false, "GET "},
+		{http.StatusFound, false, "GET "},
+		{http.StatusSeeOther, false, "GET "},
+		{http.StatusTemporaryRedirect, false, "POST payload"},
+		{http.StatusPermanentRedirect, false, "POST payload"},
+		{http.StatusMovedPermanently, true, "POST payload"},
+		{http.StatusFound, true, "POST payload"},
+		{http.StatusSeeOther, true, "GET "},
+		{http.StatusTemporaryRedirect, true, "POST payload"},
+		{http.StatusPermanentRedirect, true, "POST payload"},
+	}
+	for _, test := range tests {
+		received = ""
+		opt := request.NewOptions()
+		opt.PreserveMethodOnRedirect = test.preserve
+		if _, err := Post(fmt.Sprintf("%s/redirect/%d", server.URL, test.code), []byte("payload"), opt); err != nil {
+			t.Fatalf("%d (preserve %t): %v", test.code, test.preserve, err)
+		}
+		if received != test.want {
+			t.Errorf("%d (preserve %t): expected %q, got %q", test.code, test.preserve, test.want, received)
+		}
+	}
+}
+
+func TestRedirectedComparesRequestURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/aaaa":
+			// /aaaa and /bbbb have URLs of the same length
+			http.Redirect(w, r, "/bbbb", http.StatusFound)
+		case "/dir":
+			http.Redirect(w, r, "/dir/", http.StatusMovedPermanently)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		url      string
+		location string
+	}{
+		{server.URL + "/aaaa", server.URL + "/bbbb"},
+		{server.URL + "/dir", server.URL + "/dir/"},
+		{server.URL + "/c", ""},
+		{server.URL, ""},
+		{server.URL + "/", ""},
+	}
+	for _, test := range tests {
+		resp, err := Get(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Redirected != (test.location != "") || resp.Location != test.location {
+			t.Errorf("%s: expected location %q, got redirected=%t location=%q", test.url, test.location, resp.Redirected, resp.Location)
+		}
+	}
+}
+
+func TestSequentialRedirectsShareClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// /chain/n redirects n times before reaching /end
+		var n int
+		if _, err := fmt.Sscanf(r.URL.Path, "/chain/%d", &n); err == nil && n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/chain/%d", n-1), http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	global := request.NewOptions()
+	global.MaxRedirects = 3
+	c := New(global)
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(server.URL + "/chain/3")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if resp.Location != server.URL+"/chain/0" {
+			t.Fatalf("request %d: expected the chain to complete, got %q", i, resp.Location)
+		}
+	}
+	if _, err := c.Get(server.URL + "/chain/4"); !errors.Is(err, ErrMaxRedirects) {
+		t.Fatalf("expected a longer chain to exceed the limit, got %v", err)
+	}
+}
+
+func TestTreatAsRedirectReportsConsistentStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/legacy" {
+			w.Header().Set("Location", "/end")
+		}
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.TreatAsRedirect(http.StatusOK)
+	opt.WithRedirectHistory()
+	var seen []string
+	opt.AddResponseInterceptor(func(resp *http.Response) error {
+		seen = append(seen, fmt.Sprintf("%d %s", resp.StatusCode, resp.Status))
+		return nil
+	})
+	resp, err := Get(server.URL+"/legacy", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Redirects) != 1 || resp.Redirects[0].StatusCode != http.StatusFound || resp.Redirects[0].Status != "302 Found" {
+		t.Fatalf("expected the redirect to be recorded as 302 Found, got %+v", resp.Redirects)
+	}
+	if len(seen) != 2 || seen[0] != "302 302 Found" || seen[1] != "200 200 OK" {
+		t.Fatalf("unexpected responses seen by the interceptor %q", seen)
+	}
+}
+
+func TestRequestLifecycleCallbacksPerHop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/start" {
+			http.Redirect(w, r, "/end?done=1", http.StatusFound)
+			return
+		}
+		w.Write([]byte("finished"))
+	}))
+	defer server.Close()
+
+	var started, completed []string
+	opt := request.NewOptions()
+	opt.OnRequestStart = func(method, url string) {
+		started = append(started, method+" "+url)
+	}
+	opt.OnRequestComplete = func(resp response.Response, err error) {
+		completed = append(completed, fmt.Sprintf("%d %s %s %v", resp.StatusCode, resp.URL, resp.String(), err))
+	}
+	if _, err := Get(server.URL+"/start", opt); err != nil {
+		t.Fatal(err)
+	}
+	wantStarted := []string{"GET " + server.URL + "/start", "GET " + server.URL + "/end?done=1"}
+	if fmt.Sprint(started) != fmt.Sprint(wantStarted) {
+		t.Fatalf("expected the start of each hop %q, got %q", wantStarted, started)
+	}
+	// The final response is reported with its body, the redirect with its own URL
+	wantCompleted := []string{"302 " + server.URL + "/start  <nil>", "200 " + server.URL + "/start finished <nil>"}
+	if fmt.Sprint(completed) != fmt.Sprint(wantCompleted) {
+		t.Fatalf("expected the completion of each hop %q, got %q", wantCompleted, completed)
+	}
+
+	opt.OnRequestComplete = func(resp *http.Response, err error) {}
+	if _, err := Get(server.URL+"/start", opt); err == nil {
+		t.Fatal("expected an error for an OnRequestComplete of the wrong type")
+	}
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/caelisco/http-client/request"
+)
+
+// Relay performs an HTTP GET to srcURL and streams the response body, without buffering it, as the payload
+// of a request to dstURL using the given method, typically POST or PUT.
+// The Content-Type, Content-Language and Content-Disposition of the source are copied on to the request,
+// along with any header RequestOptions.RelayHeaderFilter accepts.
+// The RequestOptions apply to both requests, except the body and output options which only apply to dstURL.
+// Returns the HTTP response from dstURL and an error if any.
+func Relay(srcURL string, dstURL string, method string, opt ...RequestOptions) (Response, error) {
+	option, src, err := relayOptions(func(option RequestOptions) (Response, error) {
+		return doRequest(streamClient, http.MethodGet, srcURL, nil, option)
+	}, srcURL, dstURL, opt...)
+	if err != nil {
+		return Response{}, err
+	}
+	defer src.Close()
+	return doRequest(streamClient, method, dstURL, nil, option)
+}
+
+// relayHeaders lists the headers of the source response which are copied on to the destination request
+// by default. Other headers describe the source response rather than the body, i.e.: Set-Cookie or ETag.
+var relayHeaders = map[string]bool{
+	"Content-Disposition": true,
+	"Content-Language":    true,
+	"Content-Type":        true,
+}
+
+// framingHeaders describe the framing of the source body and are never relayed.
+var framingHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Encoding":  true,
+	"Content-Length":    true,
+	"Keep-Alive":        true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// relayOptions performs the GET to srcURL with get and returns the RequestOptions for the destination request,
+// with the source body set as the BodyStream. The source body is closed once it has been streamed. The caller
+// must close the returned source body as well, in case the destination request fails before it is streamed.
+func relayOptions(get func(RequestOptions) (Response, error), srcURL string, dstURL string, opt ...RequestOptions) (RequestOptions, io.Closer, error) {
+	var option RequestOptions
+	if len(opt) == 0 {
+		option = request.NewOptions()
+	} else {
+		option = opt[0]
+	}
+
+	// Validate both URLs before anything is sent
+	if _, err := checkURL(srcURL, option); err != nil {
+		return option, nil, err
+	}
+	if _, err := checkURL(dstURL, option); err != nil {
+		return option, nil, err
+	}
+
+	src, err := get(sourceOptions(option))
+	if err != nil {
+		if src.Stream != nil {
+			src.Stream.Close()
+		}
+		return option, nil, err
+	}
+	if src.StatusCode < 200 || src.StatusCode > 299 {
+		src.Stream.Close()
+		return option, nil, fmt.Errorf("relay source %s returned %s", src.URL, src.Status)
+	}
+
+	// Each header is sent once, so the values of a repeated header are combined in to one list
+	for key := range src.Header {
+		if framingHeaders[key] || !(relayHeaders[key] || (option.RelayHeaderFilter != nil && option.RelayHeaderFilter(key))) {
+			continue
+		}
+		if !option.HasHeader(key) {
+			option.AddHeader(key, strings.Join(src.Header.Values(key), ", "))
+		}
+	}
+
+	option.BodyStream = func(w io.Writer) error {
+		defer src.Stream.Close()
+		_, err := io.Copy(w, src.Stream)
+		return err
+	}
+	option.BodyRewind = nil
+
+	// The body is sent with the length of the source, unless it is changed on the way
+	length := src.ContentLength
+	if length >= 0 && src.Header.Get("Content-Encoding") == "" && len(option.ResponseTransforms) == 0 &&
+		option.Compression == request.CompressionNone && len(option.RequestTransforms) == 0 && !option.ForceChunked {
+		option.RequestInterceptors = append(slices.Clone(option.RequestInterceptors), func(req *http.Request) error {
+			req.ContentLength = length
+			return nil
+		})
+	}
+	return option, src.Stream, nil
+}
+
+// sourceOptions returns the options for the GET to the source of a relay. The body and output options
+// of the destination request are removed and the source body is left unread for the destination.
+func sourceOptions(option RequestOptions) RequestOptions {
+	option.BodyStream, option.BodyRewind = nil, nil
+	option.BodyReaderAt, option.BodyReaderAtSize = nil, 0
+	option.Compression, option.PreCompressed = request.CompressionNone, request.CompressionNone
+	option.OnUploadProgress, option.OnFileUploadProgress = nil, nil
+	option.Writer, option.SpillThreshold, option.CompressedCopy, option.ResumeFile = nil, 0, "", ""
+	option.IdempotencyKey, option.ExpectedRedirect = "", ""
+	option.StreamOutput = true
+	return option
+}
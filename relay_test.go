@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caelisco/http-client/request"
+)
+
+func TestRelayUsesOptionsForSource(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Add("X-Tag", "a")
+		w.Header().Add("X-Tag", "b")
+		w.Write([]byte("object"))
+	}))
+	defer source.Close()
+	var tags, body string
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		tags, body = r.Header.Get("X-Tag"), string(b)
+	}))
+	defer destination.Close()
+
+	opt := request.NewOptions()
+	opt.AddHeader("Authorization", "Bearer secret")
+	opt.RelayHeaderFilter = func(key string) bool { return key == "X-Tag" }
+	resp, err := Relay(source.URL, destination.URL, http.MethodPut, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || body != "object" {
+		t.Fatalf("expected the source body to be relayed, got %d %q", resp.StatusCode, body)
+	}
+	if tags != "a, b" {
+		t.Fatalf("expected every value of X-Tag to be relayed, got %q", tags)
+	}
+}
+
+func TestRelayCopiesOnlyBodyHeaders(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="report.csv"`)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Cache-Control", "no-store")
+		if r.URL.Path == "/chunked" {
+			w.Write([]byte("a,b\n"))
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte("1,2\n"))
+	}))
+	defer source.Close()
+	var received *http.Request
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		received = r
+	}))
+	defer destination.Close()
+
+	if _, err := Relay(source.URL, destination.URL, http.MethodPut); err != nil {
+		t.Fatal(err)
+	}
+	if received.Header.Get("Content-Type") != "text/csv" || received.Header.Get("Content-Disposition") == "" {
+		t.Fatalf("expected the headers describing the body to be relayed, got %v", received.Header)
+	}
+	for _, key := range []string{"Set-Cookie", "Etag", "Cache-Control"} {
+		if value := received.Header.Get(key); value != "" {
+			t.Errorf("expected %s not to be relayed, got %q", key, value)
+		}
+	}
+	if received.ContentLength != 4 || len(received.TransferEncoding) > 0 {
+		t.Fatalf("expected the Content-Length of the source to be sent, got %d %v", received.ContentLength, received.TransferEncoding)
+	}
+
+	// A source without a Content-Length is sent chunked
+	if _, err := Relay(source.URL+"/chunked", destination.URL, http.MethodPut); err != nil {
+		t.Fatal(err)
+	}
+	if received.ContentLength != -1 {
+		t.Fatalf("expected a source of unknown length to be sent chunked, got a length of %d", received.ContentLength)
+	}
+}
+
+func TestRelayClosesSourceWhenDestinationFails(t *testing.T) {
+	closed := make(chan struct{})
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("start of a large object"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(closed)
+	}))
+	defer source.Close()
+
+	opt := request.NewOptions()
+	calls := 0
+	opt.SetTokenProvider(func(context.Context) (string, error) {
+		// The source is fetched with the token, the destination is refused one
+		if calls++; calls > 1 {
+			return "", errors.New("no token for the destination")
+		}
+		return "token", nil
+	})
+	if _, err := Relay(source.URL, "http://127.0.0.1:1", http.MethodPut, opt); err == nil {
+		t.Fatal("expected the destination request to fail")
+	}
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the source body was not closed")
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import "net/http"
+
+// RemoteFileSize performs an HTTP HEAD to the specified URL and returns the Content-Length of the
+// resource, i.e.: to check the size before downloading it. ErrNoContentLength is returned when the
+// server does not send a Content-Length, and a *response.StatusError when the status is not 2xx.
+func RemoteFileSize(url string, opt ...RequestOptions) (int64, error) {
+	return remoteFileSize(Head(url, opt...))
+}
+
+// Exists performs an HTTP HEAD to the specified URL and reports if the resource exists.
+// A 2xx status is true and a 404 Not Found or 410 Gone is false. Any other status returns
+// a *response.StatusError.
+func Exists(url string, opt ...RequestOptions) (bool, error) {
+	return exists(Head(url, opt...))
+}
+
+// RemoteFileSize performs an HTTP HEAD to the specified URL and returns the Content-Length of the resource.
+// See RemoteFileSize for the errors returned.
+func (c *Client) RemoteFileSize(url string, opt ...RequestOptions) (int64, error) {
+	return remoteFileSize(c.Head(url, opt...))
+}
+
+// Exists performs an HTTP HEAD to the specified URL and reports if the resource exists.
+// See Exists for how the status is treated.
+func (c *Client) Exists(url string, opt ...RequestOptions) (bool, error) {
+	return exists(c.Head(url, opt...))
+}
+
+func remoteFileSize(resp Response, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	if err = checkStatus(resp); err != nil {
+		return 0, err
+	}
+	if resp.ContentLength < 0 {
+		return 0, ErrNoContentLength
+	}
+	return resp.ContentLength, nil
+}
+
+func exists(resp Response, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return false, nil
+	}
+	if err = checkStatus(resp); err != nil {
+		return false, err
+	}
+	return true, nil
+}
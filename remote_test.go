@@ -0,0 +1,46 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caelisco/http-client/response"
+)
+
+func TestRemoteFileSizeAndExists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file":
+			w.Header().Set("Content-Length", "1234")
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	size, err := RemoteFileSize(server.URL + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 1234 {
+		t.Fatalf("expected a size of 1234, got %d", size)
+	}
+	var statusErr *response.StatusError
+	if _, err = RemoteFileSize(server.URL + "/missing"); !errors.As(err, &statusErr) {
+		t.Fatalf("expected a *response.StatusError for a missing file, got %v", err)
+	}
+
+	if ok, err := Exists(server.URL + "/file"); err != nil || !ok {
+		t.Fatalf("expected the file to exist, got %t %v", ok, err)
+	}
+	if ok, err := Exists(server.URL + "/missing"); err != nil || ok {
+		t.Fatalf("expected a 404 not to exist, got %t %v", ok, err)
+	}
+	if _, err := Exists(server.URL + "/error"); !errors.As(err, &statusErr) {
+		t.Fatalf("expected a *response.StatusError for a server error, got %v", err)
+	}
+}
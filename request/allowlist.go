@@ -0,0 +1,80 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// URLNotAllowedError is returned when a request, or a redirect, targets a host or scheme
+// which is not in the allowlist. It is returned before any connection is made.
+type URLNotAllowedError struct {
+	URL    string
+	Reason string
+}
+
+func (e *URLNotAllowedError) Error() string {
+	return fmt.Sprintf("url %s is not allowed: %s", e.URL, e.Reason)
+}
+
+// AllowHosts restricts requests to the given hosts. A host may be an exact name or IP address,
+// a wildcard such as *.example.com which matches any subdomain, or a CIDR such as 10.0.0.0/8
+// which matches IP addresses in the range.
+// CIDR patterns are matched against the host in the URL, so a host name is not resolved to check it.
+// The allowlist of a Client cannot be widened by the options of a request.
+func (opt *Options) AllowHosts(hosts ...string) {
+	opt.AllowedHosts = append(opt.AllowedHosts, hosts...)
+}
+
+// AllowSchemes restricts requests to the given schemes, i.e.: https
+func (opt *Options) AllowSchemes(schemes ...string) {
+	opt.AllowedSchemes = append(opt.AllowedSchemes, schemes...)
+}
+
+// CheckURL returns a *URLNotAllowedError if the URL does not match the allowed hosts and schemes.
+// When no allowlist is configured every URL is allowed.
+func (opt *Options) CheckURL(u *url.URL) error {
+	if len(opt.AllowedSchemes) > 0 && !matchScheme(opt.AllowedSchemes, u.Scheme) {
+		return &URLNotAllowedError{URL: u.String(), Reason: fmt.Sprintf("scheme %q is not in the allowlist", u.Scheme)}
+	}
+	if len(opt.AllowedHosts) > 0 && !matchHost(opt.AllowedHosts, u.Hostname()) {
+		return &URLNotAllowedError{URL: u.String(), Reason: fmt.Sprintf("host %q is not in the allowlist", u.Hostname())}
+	}
+	return nil
+}
+
+func matchScheme(schemes []string, scheme string) bool {
+	for _, s := range schemes {
+		if strings.EqualFold(strings.TrimSuffix(s, "://"), scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchHost(patterns []string, host string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		switch {
+		case strings.Contains(p, "/"):
+			_, network, err := net.ParseCIDR(p)
+			if err == nil && ip != nil && network.Contains(ip) {
+				return true
+			}
+		case strings.HasPrefix(p, "*."):
+			if strings.HasSuffix(host, p[1:]) {
+				return true
+			}
+		case ip != nil:
+			if pip := net.ParseIP(p); pip != nil && pip.Equal(ip) {
+				return true
+			}
+		case p == host:
+			return true
+		}
+	}
+	return false
+}
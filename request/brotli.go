@@ -0,0 +1,26 @@
+//go:build !nobrotli
+
+package request
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Brotli support can be excluded from the build with -tags nobrotli
+func init() {
+	codecs[CompressionBrotli] = codec{
+		compressor: func(w io.Writer) (io.WriteCloser, error) {
+			return brotli.NewWriter(w), nil
+		},
+		decompressor: func(r io.Reader) (io.Reader, error) {
+			return brotli.NewReader(r), nil
+		},
+		levelCompressor: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return brotli.NewWriterLevel(w, level), nil
+		},
+		minLevel: 1,
+		maxLevel: brotli.BestCompression,
+	}
+}
@@ -0,0 +1,191 @@
+package request
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/caelisco/http-client/kv"
+)
+
+// ErrCompressionUnavailable is returned when a known compression type has been excluded from the build
+// using a build tag, i.e.: building with -tags nobrotli removes support for brotli.
+var ErrCompressionUnavailable = errors.New("compression type is not available in this build")
+
+// ErrInvalidCompression is returned when a compression level is outside the range accepted by the
+// CompressionType, or the CompressionType does not support a level.
+var ErrInvalidCompression = errors.New("invalid compression level")
+
+// codec holds the functions used to compress and decompress a CompressionType.
+// The levelled compressor is optional and accepts levels from minLevel to maxLevel.
+type codec struct {
+	compressor      func(w io.Writer) (io.WriteCloser, error)
+	decompressor    func(r io.Reader) (io.Reader, error)
+	levelCompressor func(w io.Writer, level int) (io.WriteCloser, error)
+	minLevel        int
+	maxLevel        int
+}
+
+// knownCompressions lists the compression types defined by this package, in order of preference.
+var knownCompressions = []CompressionType{CompressionGzip, CompressionDeflate, CompressionBrotli}
+
+// codecs holds the compression types compiled in to this build.
+// Optional codecs register themselves from files guarded by build tags.
+var codecs = map[CompressionType]codec{
+	CompressionGzip: {
+		compressor: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+		decompressor: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		levelCompressor: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		minLevel: gzip.BestSpeed,
+		maxLevel: gzip.BestCompression,
+	},
+	CompressionDeflate: {
+		compressor: func(w io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriter(w), nil
+		},
+		decompressor: func(r io.Reader) (io.Reader, error) {
+			return zlib.NewReader(r)
+		},
+		levelCompressor: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+		minLevel: zlib.BestSpeed,
+		maxLevel: zlib.BestCompression,
+	},
+}
+
+// codecsMu guards codecs against compressors registered at runtime
+var codecsMu sync.RWMutex
+
+// RegisterCompressor registers the functions used to compress and decompress a named CompressionType,
+// i.e.: zstd, snappy or lz4. Once registered, the type can be used with Compress and responses
+// with the matching Content-Encoding are decompressed. Registering an existing name replaces it.
+func RegisterCompressor(name string, comp func(io.Writer) (io.WriteCloser, error), decomp func(io.Reader) (io.Reader, error)) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[CompressionType(name)] = codec{compressor: comp, decompressor: decomp}
+}
+
+// lookupCodec returns the codec for the CompressionType. A known type which was not compiled in
+// returns ErrCompressionUnavailable, any other type returns an unsupported error.
+func lookupCodec(compression CompressionType) (codec, error) {
+	codecsMu.RLock()
+	c, ok := codecs[compression]
+	codecsMu.RUnlock()
+	if ok {
+		return c, nil
+	}
+	for _, known := range knownCompressions {
+		if known == compression {
+			return codec{}, fmt.Errorf("%w: %s", ErrCompressionUnavailable, compression)
+		}
+	}
+	return codec{}, fmt.Errorf("unsupported compression type: %s", compression)
+}
+
+// GetCompressor returns an io.WriteCloser which compresses everything written to it in to w
+// using the supplied CompressionType. The caller must Close the writer to flush the compressed data.
+func GetCompressor(compression CompressionType, w io.Writer) (io.WriteCloser, error) {
+	c, err := lookupCodec(compression)
+	if err != nil {
+		return nil, err
+	}
+	return c.compressor(w)
+}
+
+// GetCompressorLevel works as GetCompressor but compresses at the given level, trading speed for
+// a smaller body. Gzip and deflate accept 1 (fastest) to 9 (smallest) and brotli 1 to 11.
+// A level of zero uses the default level. ErrInvalidCompression is returned for a level outside
+// the accepted range, or for a registered CompressionType, which only has a default level.
+func GetCompressorLevel(compression CompressionType, level int, w io.Writer) (io.WriteCloser, error) {
+	c, err := lookupCodec(compression)
+	if err != nil {
+		return nil, err
+	}
+	if level == 0 {
+		return c.compressor(w)
+	}
+	if c.levelCompressor == nil {
+		return nil, fmt.Errorf("%w: %s does not support a compression level", ErrInvalidCompression, compression)
+	}
+	if level < c.minLevel || level > c.maxLevel {
+		return nil, fmt.Errorf("%w: %d is outside the range %d to %d for %s", ErrInvalidCompression, level, c.minLevel, c.maxLevel, compression)
+	}
+	return c.levelCompressor(w, level)
+}
+
+// GetDecompressor returns an io.Reader which decompresses the data read from r
+// using the supplied CompressionType.
+func GetDecompressor(compression CompressionType, r io.Reader) (io.Reader, error) {
+	c, err := lookupCodec(compression)
+	if err != nil {
+		return nil, err
+	}
+	return c.decompressor(r)
+}
+
+// IsSupported reports if the CompressionType can be compressed and decompressed by the client.
+func IsSupported(compression CompressionType) bool {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	_, ok := codecs[compression]
+	return ok
+}
+
+// AvailableCompressions returns the compression types compiled in to this build,
+// followed by any registered with RegisterCompressor in name order.
+func AvailableCompressions() []CompressionType {
+	var available []CompressionType
+	for _, compression := range knownCompressions {
+		if IsSupported(compression) {
+			available = append(available, compression)
+		}
+	}
+
+	var registered []CompressionType
+	codecsMu.RLock()
+	for compression := range codecs {
+		if !slices.Contains(knownCompressions, compression) {
+			registered = append(registered, compression)
+		}
+	}
+	codecsMu.RUnlock()
+	slices.Sort(registered)
+
+	return append(available, registered...)
+}
+
+// AcceptCompression sets the Accept-Encoding header to request a compressed response, listing the types
+// in order of preference, or every type in AvailableCompressions when none are given. Types which are not
+// available in this build are left out, so whatever the server returns can be decompressed.
+// As the header is set, the transport does not decompress the response itself and it is decompressed by
+// the package instead. A response already decompressed by the transport is never decompressed again.
+func (opt *Options) AcceptCompression(types ...CompressionType) {
+	if len(types) == 0 {
+		types = AvailableCompressions()
+	}
+	var accepted []string
+	for _, compression := range types {
+		if compression != CompressionNone && IsSupported(compression) {
+			accepted = append(accepted, string(compression))
+		}
+	}
+	if len(accepted) == 0 {
+		return
+	}
+	opt.Headers = slices.DeleteFunc(opt.Headers, func(h kv.Header) bool {
+		return strings.EqualFold(h.Key, "Accept-Encoding")
+	})
+	opt.AddHeader("Accept-Encoding", strings.Join(accepted, ", "))
+}
@@ -0,0 +1,91 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables recognised by FromEnv.
+const (
+	EnvTimeout        = "HTTP_CLIENT_TIMEOUT"         // Timeout for the whole request, i.e.: 30s or 1m
+	EnvConnectTimeout = "HTTP_CLIENT_CONNECT_TIMEOUT" // Timeout for establishing the connection, i.e.: 5s
+	EnvUserAgent      = "HTTP_CLIENT_USER_AGENT"      // User-Agent sent with the request
+	EnvMaxRedirects   = "HTTP_CLIENT_MAX_REDIRECTS"   // Maximum number of redirects to follow. 0 disables redirects
+	EnvCompression    = "HTTP_CLIENT_COMPRESSION"     // Compression for the payload: none, gzip, deflate, br or a registered type
+)
+
+// FromEnv builds Options from the environment, so the client can be configured without code changes,
+// i.e.: in a containerised deployment. Variables which are not set, or are empty, are ignored.
+//
+//	HTTP_CLIENT_TIMEOUT          duration, i.e.: 30s or 1m30s
+//	HTTP_CLIENT_CONNECT_TIMEOUT  duration, i.e.: 5s
+//	HTTP_CLIENT_USER_AGENT       string
+//	HTTP_CLIENT_MAX_REDIRECTS    non-negative integer, 0 disables redirects
+//	HTTP_CLIENT_COMPRESSION      none, gzip, deflate, br or a type added with RegisterCompressor
+//
+// Every invalid value is reported in the returned error, along with the Options built from the valid ones.
+func FromEnv() (Options, error) {
+	opt := NewOptions()
+	var errs []error
+
+	if v := os.Getenv(EnvTimeout); v != "" {
+		d, err := parseEnvDuration(EnvTimeout, v)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			opt.Timeout = d
+		}
+	}
+
+	if v := os.Getenv(EnvConnectTimeout); v != "" {
+		d, err := parseEnvDuration(EnvConnectTimeout, v)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			opt.ConnectTimeout = d
+		}
+	}
+
+	if v := os.Getenv(EnvUserAgent); v != "" {
+		opt.UserAgent = v
+	}
+
+	if v := os.Getenv(EnvMaxRedirects); v != "" {
+		n, err := strconv.Atoi(v)
+		switch {
+		case err != nil || n < 0:
+			errs = append(errs, fmt.Errorf("%s: %q is not a non-negative integer", EnvMaxRedirects, v))
+		case n == 0:
+			opt.DisableRedirect = true
+		default:
+			opt.MaxRedirects = n
+		}
+	}
+
+	if v := os.Getenv(EnvCompression); v != "" {
+		compression := CompressionType(strings.ToLower(v))
+		if compression == "none" {
+			compression = CompressionNone
+		}
+		if compression != CompressionNone && !IsSupported(compression) {
+			errs = append(errs, fmt.Errorf("%s: unsupported compression %q, available: %v", EnvCompression, v, AvailableCompressions()))
+		} else {
+			opt.Compression = compression
+		}
+	}
+
+	return opt, errors.Join(errs...)
+}
+
+// parseEnvDuration parses a positive duration such as 30s or 1m30s.
+func parseEnvDuration(name, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s: %q is not a positive duration, i.e.: 30s or 1m", name, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,67 @@
+package request
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SetLogger sets the *slog.Logger used to log the progress of the request.
+// Logging is disabled when no logger is set.
+func (opt *Options) SetLogger(logger *slog.Logger) {
+	opt.Logger = logger
+}
+
+// WithLogFields adds fields, as slog key/value pairs, which are included in every log entry for the request,
+// i.e.: opt.WithLogFields("order_id", 123)
+// This makes the logs of a specific logical operation easy to filter.
+func (opt *Options) WithLogFields(args ...any) {
+	opt.LogFields = append(opt.LogFields, args...)
+}
+
+// SetLogTimeFormat sets the layout of the time in log entries, i.e.: time.RFC3339, so it is consistent
+// with the rest of the application. The handler of the Logger is not changed: the time is logged as a
+// formatted "time" attribute in place of the handler's own time.
+func (opt *Options) SetLogTimeFormat(layout string) {
+	opt.LogTimeFormat = layout
+}
+
+// SetLogUTC logs times in UTC rather than the local time zone.
+func (opt *Options) SetLogUTC() {
+	opt.LogUTC = true
+}
+
+// Log writes a log entry at the given level using the configured Logger.
+// The LogFields are included before the args.
+func (opt *Options) Log(level slog.Level, msg string, args ...any) {
+	if opt.Logger == nil {
+		return
+	}
+	fields := make([]any, 0, len(opt.LogFields)+len(args))
+	fields = append(fields, opt.LogFields...)
+	fields = append(fields, args...)
+	if opt.LogTimeFormat == "" && !opt.LogUTC {
+		opt.Logger.Log(context.Background(), level, msg, fields...)
+		return
+	}
+
+	// Build the record so its time can be set
+	ctx := context.Background()
+	if !opt.Logger.Enabled(ctx, level) {
+		return
+	}
+	now := opt.Now()
+	if opt.LogUTC {
+		now = now.UTC()
+	}
+	var record slog.Record
+	if opt.LogTimeFormat != "" {
+		// A zero time is left out by the handler, so only the formatted time is logged
+		record = slog.NewRecord(time.Time{}, level, msg, 0)
+		record.AddAttrs(slog.String(slog.TimeKey, now.Format(opt.LogTimeFormat)))
+	} else {
+		record = slog.NewRecord(now, level, msg, 0)
+	}
+	record.Add(fields...)
+	opt.Logger.Handler().Handle(ctx, record)
+}
@@ -1,11 +1,19 @@
 package request
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/caelisco/http-client/kv"
 	"github.com/google/uuid"
@@ -14,6 +22,7 @@ import (
 
 type CompressionType string
 type UniqueIdentifierType string
+type FramingType string
 
 const (
 	CompressionNone    CompressionType = ""
@@ -29,19 +38,97 @@ const (
 	IdentifierULID UniqueIdentifierType = "ulid"
 )
 
+const (
+	FramingNewline      FramingType = ""              // Messages are separated by a line feed
+	FramingLengthPrefix FramingType = "length-prefix" // Messages follow their length as a 4 byte big endian integer
+)
+
 // RequestOptions represents additional options for the HTTP request.
 //
 // DisableRedirect - Determines if redirects should be followed or not. The default option is
 // false which means redirects will be followed.
+//
+// PreserveMethodOnRedirect - Redirects follow the status code: a 303 always switches to GET and drops
+// the body, while a 307 or 308 keeps the method and body. A 301 or 302 historically switches a POST
+// to GET, which is the default. Set PreserveMethodOnRedirect to keep the method and body instead.
 type Options struct {
-	Headers          []kv.Header          // Custom headers to be added to the request
-	Cookies          []*http.Cookie       // Cookies to be included in the request
-	ProtocolScheme   string               // define a custom protocol scheme. It defaults to https
-	Compression      CompressionType      // CompressionType to use: none, gzip, deflate or brotli
-	UserAgent        string               // User Agent to send with requests
-	DisableRedirect  bool                 // Disable or enable redirects. Default is false - do not disable redirects
-	UniqueIdentifier UniqueIdentifierType // Internal trace or identifier for the request
-	Writer           io.WriteCloser       // Define a custom resource you will write to other than the bytes.Buffer i.e.: a file
+	Headers                     []kv.Header                                               // Custom headers to be added to the request
+	Cookies                     []*http.Cookie                                            // Cookies to be included in the request
+	QueryParams                 url.Values                                                // Query parameters added to the URL, after any already in the URL
+	ProtocolScheme              string                                                    // define a custom protocol scheme. It defaults to https
+	Compression                 CompressionType                                           // CompressionType to use: none, gzip, deflate or brotli
+	CompressionLevel            int                                                       // Level the request body is compressed at. Zero uses the default level
+	UserAgent                   string                                                    // User Agent to send with requests
+	DisableRedirect             bool                                                      // Disable or enable redirects. Default is false - do not disable redirects
+	UniqueIdentifier            UniqueIdentifierType                                      // Internal trace or identifier for the request
+	Writer                      io.WriteCloser                                            // Define a custom resource you will write to other than the bytes.Buffer i.e.: a file
+	BodyStream                  func(io.Writer) error                                     // Streams the request body through an io.Pipe instead of sending the payload
+	BodyRewind                  func() error                                              // Returns the reader of a body stream to its start so the body can be sent again
+	BodyReaderAt                io.ReaderAt                                               // Sends the body from a section of an io.ReaderAt instead of the payload
+	BodyReaderAtSize            int64                                                     // Size of the body read from BodyReaderAt
+	Clock                       func() time.Time                                          // Time source used for identifiers and timestamps. Defaults to time.Now
+	RedirectHistory             bool                                                      // Keep the intermediate redirect responses on the final response
+	PreCompressed               CompressionType                                           // Encoding of a payload which has already been compressed by the caller
+	HeaderFuncs                 []kv.HeaderFunc                                           // Headers which are computed each time the request is sent
+	ConnectTimeout              time.Duration                                             // Maximum time allowed to establish a connection
+	ExpectContinueTimeout       time.Duration                                             // Time to wait for a 100 Continue before the body is sent anyway. Zero does not send Expect
+	Timeout                     time.Duration                                             // Overall deadline for the request, including redirects and reading the body
+	CompressedCopy              string                                                    // Path to a file which receives the raw, still compressed, response body
+	IdempotencyKey              string                                                    // Sent as the Idempotency-Key header and kept stable across redirects
+	OnDownloadProgress          func(bytesRead, totalBytes int64)                         // Called as the response body is read. totalBytes is -1 when unknown
+	WireProgress                bool                                                      // Report download progress against the bytes read from the wire
+	OnFileUploadProgress        func(field, filename string, bytesRead, totalBytes int64) // Called as each file of a multipart upload is sent
+	OnRequestStart              func(method, url string)                                  // Called before each request is sent, including redirects
+	OnRequestComplete           any                                                       // A func(resp response.Response, err error) called as each redirect is followed and once the request returns
+	TokenProvider               func(ctx context.Context) (string, error)                 // Returns the bearer token sent in the Authorization header
+	PaginationCursor            any                                                       // A func(response.Response) (nextURL string, done bool) used by GetAll to find the next page
+	AllowedHosts                []string                                                  // Hosts, wildcards or CIDRs requests are allowed to reach. Empty allows all
+	AllowedSchemes              []string                                                  // Schemes requests are allowed to use. Empty allows all
+	RelayHeaderFilter           func(key string) bool                                     // Decides which other source headers are copied by Relay, besides Content-Type, Content-Language and Content-Disposition
+	StrictContentLength         bool                                                      // Error if the response body is shorter than the Content-Length
+	DefaultContentType          string                                                    // Content-Type used for a payload when no Content-Type header is set
+	SpillThreshold              int64                                                     // Size in bytes after which a buffered body is moved to a temporary file
+	SpillDir                    string                                                    // Directory for the temporary file. Empty uses os.TempDir
+	RequireJSON                 bool                                                      // Error if the response is not JSON
+	ValidateUTF8                bool                                                      // Error if the decoded, buffered response body is not valid UTF-8
+	LocalAddr                   net.Addr                                                  // Local address connections are made from, i.e.: a specific source IP
+	ForceChunked                bool                                                      // Always send the payload with chunked transfer encoding
+	Logger                      *slog.Logger                                              // Logger for the request. Nil disables logging
+	LogFields                   []any                                                     // Fields included in every log entry for the request
+	LogTimeFormat               string                                                    // Layout of the time in log entries, i.e.: time.RFC3339. Empty uses the handler's format
+	LogUTC                      bool                                                      // Log times in UTC
+	LenientDecoding             bool                                                      // Fall back to the raw body when it is not compressed as declared
+	OnUploadProgress            func(bytesWritten, totalBytes int64)                      // Called as the request body is sent. totalBytes is -1 when unknown
+	PreserveMethodOnRedirect    bool                                                      // Keep the method and body on a 301 or 302 redirect instead of switching to GET
+	ChecksumTrailer             string                                                    // Trailer holding the checksum of the response body
+	ChecksumHeader              string                                                    // Header holding the checksum of the response body
+	ChecksumAlgorithm           string                                                    // Algorithm used to verify the checksum: md5, sha1, sha256, sha512 or crc32c
+	TLSServerName               string                                                    // Server name sent for SNI and used to verify the certificate
+	InsecureSkipVerify          bool                                                      // Skip verification of the server certificate chain and host name. Only for testing
+	ClientCertificates          []tls.Certificate                                         // Certificates presented to the server for mutual TLS
+	RootCAs                     *x509.CertPool                                            // Root certificate authorities used to verify the server instead of the system pool
+	MinTLSVersion               uint16                                                    // Minimum TLS version accepted, i.e.: tls.VersionTLS12
+	TLSSessionCache             bool                                                      // Resume TLS sessions from a cache shared between requests to reduce handshake cost
+	Proxy                       *url.URL                                                  // Proxy used for the request instead of the proxy from the environment
+	UploadRateLimit             int64                                                     // Maximum upload rate in bytes per second. Zero is unlimited
+	DownloadRateLimit           int64                                                     // Maximum download rate in bytes per second. Zero is unlimited
+	DisableTransportCompression bool                                                      // Leave requesting and decoding compressed responses to the package rather than the transport
+	MaxRedirects                int                                                       // Maximum number of redirects to follow. Zero uses the default limit of 10
+	StreamOutput                bool                                                      // Leave the response body unread in Response.Stream for the caller to read and close
+	ExpectedRedirect            string                                                    // URL the request is expected to redirect to. A * matches any characters
+	RequestInterceptors         []func(*http.Request) error                               // Called in order before each request is sent, including redirects
+	RequestTransforms           []func(io.Writer) io.WriteCloser                          // Applied in order to the request body before it is compressed and sent
+	ResponseInterceptors        []func(*http.Response) error                              // Called in order once the headers of each response are received, including redirects
+	ResponseTransforms          []func(io.Reader) (io.Reader, error)                      // Applied in order to the response body after it is decompressed
+	TransformFrom               CompressionType                                           // Content-Encoding of a response to re-encode with TransformTo
+	TransformTo                 CompressionType                                           // Compression the decoded response body is re-encoded with before it is written
+	MaxDownloadBytes            int64                                                     // Stop reading the response body after this many decoded bytes, marking it Truncated
+	ResumeFile                  string                                                    // File a download is written to and resumed in with a Range request
+	AutoResume                  bool                                                      // Continue a GET body with a Range request when the connection fails part way
+	MaxResponseSize             int64                                                     // Error with ErrResponseTooLarge once the decoded response body exceeds this many bytes
+	RedirectCodes               []int                                                     // Additional status codes followed as a redirect when the response has a Location header
+	Framing                     FramingType                                               // How Subscribe splits a streamed response in to messages
+	MaxMessageSize              int                                                       // Largest message Subscribe reads. Zero uses the default of 16 MiB
 }
 
 func NewOptions() Options {
@@ -56,6 +143,23 @@ func (opt *Options) AddHeader(key string, value string) {
 	opt.Headers = append(opt.Headers, kv.Header{Key: key, Value: value})
 }
 
+// AddHeaderFunc adds a header whose value is computed by fn each time the request is sent,
+// including when following a redirect. This keeps time sensitive values such as
+// timestamps, nonces or signatures fresh, where a static header would go stale.
+func (opt *Options) AddHeaderFunc(key string, fn func() string) {
+	opt.HeaderFuncs = append(opt.HeaderFuncs, kv.HeaderFunc{Key: key, Value: fn})
+}
+
+// HasHeader reports if a header with the given key has been added. The key is case insensitive.
+func (opt *Options) HasHeader(key string) bool {
+	for _, h := range opt.Headers {
+		if strings.EqualFold(h.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
 // ListHeaders prints out the list of headers in the RequestOptions.
 func (opt *Options) ListHeaders() {
 	for _, h := range opt.Headers {
@@ -88,6 +192,24 @@ func (opt *Options) ClearCookies() {
 	opt.Cookies = nil
 }
 
+// AddQueryParam adds a query parameter to the URL of the request. The key and value are escaped
+// when the URL is built. A repeated key is sent once for each value.
+func (opt *Options) AddQueryParam(key string, value string) {
+	if opt.QueryParams == nil {
+		opt.QueryParams = url.Values{}
+	}
+	opt.QueryParams.Add(key, value)
+}
+
+// SetQueryParams replaces the query parameters added to the URL of the request. Parameters already
+// in the URL passed to the request are kept.
+func (opt *Options) SetQueryParams(params url.Values) {
+	opt.QueryParams = url.Values{}
+	for key, values := range params {
+		opt.QueryParams[key] = append([]string(nil), values...)
+	}
+}
+
 func (opt *Options) SetProtocolScheme(scheme string) {
 	if !strings.Contains(scheme, "://") {
 		scheme += "://"
@@ -95,10 +217,161 @@ func (opt *Options) SetProtocolScheme(scheme string) {
 	opt.ProtocolScheme = scheme
 }
 
+// SetDefaultContentType sets the Content-Type sent with a payload when no Content-Type header has been added,
+// i.e.: application/octet-stream or text/plain; charset=utf-8
+// Requests without a payload are not affected. This avoids leaving the server to guess the content type.
+func (opt *Options) SetDefaultContentType(contentType string) {
+	opt.DefaultContentType = contentType
+}
+
+// ForceChunkedUpload always sends the payload using chunked transfer encoding, without a Content-Length,
+// even when the size of the payload is known. This gives control over the framing for servers which
+// mishandle a Content-Length.
+func (opt *Options) ForceChunkedUpload() {
+	opt.ForceChunked = true
+}
+
+// SetUploadRateLimit limits the rate the payload is sent to bytesPerSec, i.e.: to be a polite uploader or
+// to simulate a slow mobile network in integration tests. Upload progress reflects the slowed rate.
+func (opt *Options) SetUploadRateLimit(bytesPerSec int64) {
+	opt.UploadRateLimit = bytesPerSec
+}
+
+// SetDownloadRateLimit limits the rate the response body is read to bytesPerSec, i.e.: for polite crawling
+// or to test how downstream code handles slow streams. Waiting is abandoned when the request times out.
+func (opt *Options) SetDownloadRateLimit(bytesPerSec int64) {
+	opt.DownloadRateLimit = bytesPerSec
+}
+
+// SetTransportCompression chooses who negotiates and decodes compressed responses. Enabled, the default,
+// leaves gzip to the http.Transport. Disabled, the package sends Accept-Encoding: gzip and decodes
+// the response itself, as it does whenever the Accept-Encoding header is set.
+func (opt *Options) SetTransportCompression(enabled bool) {
+	opt.DisableTransportCompression = !enabled
+}
+
+// SetMaxRedirects sets the maximum number of redirects followed before the request fails with ErrMaxRedirects.
+// Use DisableRedirect to not follow redirects at all.
+func (opt *Options) SetMaxRedirects(max int) {
+	opt.MaxRedirects = max
+}
+
 func (opt *Options) Compress(compressionType CompressionType) {
 	opt.Compression = compressionType
 }
 
+// SetCompressionLevel sets the level the request body is compressed at, trading speed for a smaller body,
+// i.e.: 1 for a CPU constrained upload. Gzip and deflate accept 1 to 9 and brotli 1 to 11. A level outside
+// the range of the CompressionType fails the request with ErrInvalidCompression.
+func (opt *Options) SetCompressionLevel(level int) {
+	opt.CompressionLevel = level
+}
+
+// SetPreCompressed declares that the payload is already compressed with the given encoding.
+// The Content-Encoding header is set and the payload is sent as-is, without the client
+// compressing it again. This separates declaring the encoding from compressing the payload.
+func (opt *Options) SetPreCompressed(encoding CompressionType) {
+	opt.PreCompressed = encoding
+}
+
+// SetTimeout sets the deadline for the whole request, including any redirects, without changing the
+// http.Client shared with other requests. The Timeout of the http.Client still applies, so whichever
+// is reached first wins.
+func (opt *Options) SetTimeout(d time.Duration) {
+	opt.Timeout = d
+}
+
+// FailFast sets a short connectTimeout for establishing the connection along with a more
+// generous totalTimeout for the whole request. A connection that cannot be established fails
+// quickly, while a slow but healthy response is given the full budget.
+// Whichever deadline is reached first wins.
+func (opt *Options) FailFast(connectTimeout, totalTimeout time.Duration) {
+	opt.ConnectTimeout = connectTimeout
+	opt.Timeout = totalTimeout
+}
+
+// SetExpectContinueTimeout sends the request with Expect: 100-continue, so the body is only sent once
+// the server has accepted the headers, i.e.: a large upload which may be rejected for authorisation.
+// If no 100 Continue arrives within the timeout, the body is sent anyway.
+// A BodyStream, compressed or not, is not written until the transport is ready to send it.
+func (opt *Options) SetExpectContinueTimeout(timeout time.Duration) {
+	opt.ExpectContinueTimeout = timeout
+}
+
+// SetIdempotencyKey sets the Idempotency-Key header used by idempotency aware APIs to safely
+// replay requests such as a POST. If key is empty, a UUID is generated.
+// The key is generated once, so it remains the same when a redirect is followed.
+// Note that reusing the same Options for a different logical request will reuse the key.
+func (opt *Options) SetIdempotencyKey(key string) {
+	if key == "" {
+		key = uuid.New().String()
+	}
+	opt.IdempotencyKey = key
+}
+
+// SetLocalAddr sets the local address used when dialing, so requests egress from a specific source IP
+// on a multi-homed host. For TCP the address should be a *net.TCPAddr, usually with a zero port.
+// It is combined with the ConnectTimeout when both are set.
+func (opt *Options) SetLocalAddr(addr net.Addr) {
+	opt.LocalAddr = addr
+}
+
+// SetTLSServerName sets the server name sent for SNI and used to verify the certificate, i.e.: when
+// connecting to a load balancer by IP address while presenting the name of the virtual host.
+// Any other TLS settings of the client transport are kept.
+func (opt *Options) SetTLSServerName(name string) {
+	opt.TLSServerName = name
+}
+
+// SetInsecureSkipVerify disables verification of the server certificate chain and host name.
+// This makes the connection open to interception and should only be used for testing.
+func (opt *Options) SetInsecureSkipVerify(skip bool) {
+	opt.InsecureSkipVerify = skip
+}
+
+// SetClientCertificate sets the certificate presented to the server for mutual TLS.
+func (opt *Options) SetClientCertificate(cert tls.Certificate) {
+	opt.ClientCertificates = []tls.Certificate{cert}
+}
+
+// SetRootCAs sets the certificate authorities used to verify the server, i.e.: a private CA.
+// The system pool is used when no pool is set.
+func (opt *Options) SetRootCAs(pool *x509.CertPool) {
+	opt.RootCAs = pool
+}
+
+// SetMinTLSVersion sets the minimum TLS version accepted for the connection, i.e.: tls.VersionTLS13
+func (opt *Options) SetMinTLSVersion(version uint16) {
+	opt.MinTLSVersion = version
+}
+
+// EnableTLSSessionCache keeps TLS sessions in a cache shared between requests, so connections to a host
+// that was already visited resume the session rather than performing a full handshake. This helps most
+// when connections are not pooled, i.e.: many short requests that each use their own transport.
+// A Client created with New uses a session cache by default.
+func (opt *Options) EnableTLSSessionCache() {
+	opt.TLSSessionCache = true
+}
+
+// SetProxy routes the request through the proxy at proxyURL instead of the proxy from the environment.
+// The scheme must be http, https, socks5 or socks5h. Other requests using the same client are not affected.
+func (opt *Options) SetProxy(proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q: must be http, https, socks5 or socks5h", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid proxy url %q: missing host", proxyURL)
+	}
+	opt.Proxy = u
+	return nil
+}
+
 func (opt *Options) DisableRedirects() bool {
 	return true
 }
@@ -107,12 +380,34 @@ func (opt *Options) EnableRedirects() bool {
 	return false
 }
 
+// SetClock sets the time source used when generating ULIDs and when recording the
+// timestamps on the response. It is mainly intended for tests which require stable times.
+// Note that the ULID entropy is still random, so only the timestamp portion is fixed.
+func (opt *Options) SetClock(clock func() time.Time) {
+	opt.Clock = clock
+}
+
+// Now returns the current time from the configured Clock, or time.Now if none is set.
+func (opt *Options) Now() time.Time {
+	if opt.Clock == nil {
+		return time.Now()
+	}
+	return opt.Clock()
+}
+
+// WithRedirectHistory records each intermediate redirect response (status, headers and Location)
+// on the final response.Response. The bodies of the intermediate responses are not kept.
+// It is off by default to avoid the overhead.
+func (opt *Options) WithRedirectHistory() {
+	opt.RedirectHistory = true
+}
+
 func (opt *Options) GenerateIdentifier() string {
 	switch opt.UniqueIdentifier {
 	case IdentifierUUID:
 		return uuid.New().String()
 	case IdentifierULID:
-		return ulid.Make().String()
+		return ulid.MustNew(ulid.Timestamp(opt.Now()), ulid.DefaultEntropy()).String()
 	}
 	return ""
 }
@@ -126,12 +421,268 @@ func (opt *Options) FileWriter(filename string) error {
 	return nil
 }
 
+// SetBodyFunc sets a function which writes the request body. The function is run in a goroutine
+// and writes in to an io.Pipe which is streamed as the body, so a body can be generated procedurally,
+// i.e.: rows from a database streamed as a CSV upload, without buffering it in memory.
+// The length is unknown, so the body is sent chunked. An error returned by fn aborts the request.
+// Any payload passed to the request is ignored when a body function is set.
+func (opt *Options) SetBodyFunc(fn func(w io.Writer) error) {
+	opt.BodyStream = fn
+	opt.BodyRewind = nil
+}
+
+// SetBodyReader sends the content of r as the request body instead of the payload, i.e.: from an io.Pipe
+// or a network stream. The length is unknown, so the body is sent chunked and OnUploadProgress is called
+// with a totalBytes of -1. If r is an io.ReadSeeker, it is seeked back to its current offset when a redirect
+// or retry requires the body to be sent again. Any other reader is read once and cannot be replayed.
+func (opt *Options) SetBodyReader(r io.Reader) {
+	opt.BodyStream = func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	opt.BodyRewind = nil
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if offset, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			opt.BodyRewind = func() error {
+				_, err := rs.Seek(offset, io.SeekStart)
+				return err
+			}
+		}
+	}
+}
+
+// SetReaderAtBody sends size bytes read from ra as the request body instead of the payload, i.e.: from an
+// in-memory blob or a memory mapped region. The body is read through an io.SectionReader, so it can be
+// replayed when a redirect requires the body to be sent again. With compression, the body is compressed
+// as it is sent and cannot be replayed.
+func (opt *Options) SetReaderAtBody(ra io.ReaderAt, size int64) {
+	opt.BodyReaderAt = ra
+	opt.BodyReaderAtSize = size
+}
+
+// SetJSONBodyStream encodes v directly in to the request body using a json.Encoder.
+// The body is streamed through an io.Pipe so the serialised document is never held in memory,
+// which is useful for uploading very large generated JSON documents.
+// Any payload passed to the request is ignored when a body stream is set.
+func (opt *Options) SetJSONBodyStream(v any) {
+	opt.AddHeader("Content-Type", "application/json")
+	opt.BodyStream = func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	}
+	opt.BodyRewind = nil
+}
+
+// KeepCompressedCopy writes the raw response body, as it was received on the wire, to the file at path
+// while the Writer (or Body) receives the decompressed content. This is useful for caches which
+// store the compressed representation while the application uses the decoded form.
+// If no Accept-Encoding header is set, gzip is requested so the body is not decoded by the transport.
+func (opt *Options) KeepCompressedCopy(path string) {
+	opt.CompressedCopy = path
+}
+
+// TrackDownloadByWireBytes reports OnDownloadProgress against the compressed bytes read from the wire
+// rather than the decompressed bytes. The compressed size is known from the Content-Length, so the
+// progress reaches a real percentage where the decompressed total would be unknown (-1).
+// If no Accept-Encoding header is set, gzip is requested so the body is not decoded by the transport.
+func (opt *Options) TrackDownloadByWireBytes() {
+	opt.WireProgress = true
+}
+
+// EnforceContentLength returns a *response.ContentLengthError if the server sends fewer bytes than its
+// Content-Length header declares, detecting a truncated response. Bytes beyond the Content-Length are never
+// read, as net/http ends the body at the declared length. It only applies when the Content-Length is present
+// and the body is not chunked.
+func (opt *Options) EnforceContentLength() {
+	opt.StrictContentLength = true
+}
+
+// SetBufferSpillToFile protects against unexpectedly large responses when the body is buffered in memory.
+// Once the body exceeds threshold bytes it is moved to a temporary file in dir, and the rest of the body
+// is written to the file. The path is available in response.SpillFile and Bytes() and String() read from it.
+// The caller is responsible for removing the temporary file. An empty dir uses os.TempDir.
+// It has no effect when a Writer is set.
+func (opt *Options) SetBufferSpillToFile(threshold int64, dir string) {
+	opt.SpillThreshold = threshold
+	opt.SpillDir = dir
+}
+
+// ExpectJSON sets the Accept header to application/json and validates that the response is JSON.
+// If the server responds with anything else, such as an HTML error page, a *response.ContentTypeError
+// is returned which includes the start of the body, instead of failing later when parsing.
+// The body is not written to the Writer when the validation fails.
+func (opt *Options) ExpectJSON() {
+	opt.AddHeader("Accept", "application/json")
+	opt.RequireJSON = true
+}
+
+// RequireUTF8 validates that the response body is valid UTF-8 as it is read, returning a *response.UTF8Error
+// with the offset of the first invalid byte otherwise. The body is validated after it is decompressed and
+// after any response transforms, so a transcoded body is checked once it has been converted.
+// It only applies to a buffered body, not one written to a Writer or file or handed to the caller with SetStreamOutput.
+func (opt *Options) RequireUTF8() {
+	opt.ValidateUTF8 = true
+}
+
+// LenientDecompression handles misconfigured servers which declare a Content-Encoding, i.e.: gzip,
+// but send the body uncompressed. If the start of the body cannot be decompressed, the raw body
+// is used and a warning is logged, rather than failing the request. The default is strict.
+// If no Accept-Encoding header is set, gzip is requested so the body is not decoded by the transport.
+func (opt *Options) LenientDecompression() {
+	opt.LenientDecoding = true
+}
+
+// VerifyTrailerChecksum computes the checksum of the response body as it is read and, once the body
+// and its trailers have been received, compares it with the digest in the named trailer, i.e.: x-goog-hash.
+// A *response.ChecksumError is returned on a mismatch, or if the trailer is missing.
+// The algorithm is one of md5, sha1, sha256, sha512 or crc32c, and the digest may be hex or base64 encoded.
+// The checksum is computed over the decompressed body.
+func (opt *Options) VerifyTrailerChecksum(trailerName string, algorithm string) {
+	opt.ChecksumTrailer = trailerName
+	opt.ChecksumAlgorithm = algorithm
+}
+
+// VerifyChecksumFromHeader computes the checksum of the response body as it is read and compares it
+// with the digest in the named response header, i.e.: Content-MD5 or x-amz-checksum-sha256.
+// A *response.ChecksumError is returned on a mismatch, or if the header is missing.
+// The algorithm and digest formats are those of VerifyTrailerChecksum, which uses the same algorithm
+// when both are set. The checksum is computed over the decompressed body.
+func (opt *Options) VerifyChecksumFromHeader(headerName string, algorithm string) {
+	opt.ChecksumHeader = headerName
+	opt.ChecksumAlgorithm = algorithm
+}
+
+// SetStreamOutput leaves the response body unread in Response.Stream, so a large body can be processed
+// incrementally, i.e.: line by line NDJSON. The body is decompressed as it is read.
+// The caller owns the stream and must close it. The connection, and any timeout set for the request,
+// are held until it is closed. The Writer, spill, content length and checksum options do not apply.
+func (opt *Options) SetStreamOutput() {
+	opt.StreamOutput = true
+}
+
+// ExpectRedirectTo makes the request fail with a *response.RedirectError unless it was redirected to
+// the URL, i.e.: to assert an endpoint redirects to the login page in an integration test.
+// Either the first redirect target or the final URL may match. The match is exact, unless the URL
+// contains a *, which matches any characters, i.e.: https://cdn.example.com/* for a prefix.
+func (opt *Options) ExpectRedirectTo(url string) {
+	opt.ExpectedRedirect = url
+}
+
+// AddRequestInterceptor adds a function called with the request just before it is sent, and before each
+// redirect is followed, i.e.: to add tracing headers. Interceptors run in the order they were added and
+// an error aborts the request and is returned to the caller.
+func (opt *Options) AddRequestInterceptor(fn func(*http.Request) error) {
+	opt.RequestInterceptors = append(opt.RequestInterceptors, fn)
+}
+
+// SetTokenProvider sets a function which returns the bearer token sent in the Authorization header,
+// i.e.: an OAuth access token. The provider is called before each request. If the server responds
+// 401 Unauthorized, the provider is called again to refresh the token and the request is retried once,
+// so the provider should return a new token when it is called after a rejection. The provider is given
+// a context bound by the request Timeout. A body stream which cannot be replayed, i.e.: from SetBodyFunc or
+// SetBodyReader with a reader which is not an io.ReadSeeker, is not retried and the 401 response is returned.
+func (opt *Options) SetTokenProvider(provider func(ctx context.Context) (string, error)) {
+	opt.TokenProvider = provider
+}
+
+// AddRequestTransform adds a function which wraps the writer the request body is written to, i.e.: to
+// encrypt the body on the client. The body passes through the transforms in the order they were added,
+// and then through the compression set with Compress, so encrypt then compress is two calls. Each writer
+// is closed once the body has been written. The body is streamed and sent chunked, so it cannot be replayed
+// when a redirect requires the body to be sent again. Any headers describing the encoding must be set by
+// the caller.
+func (opt *Options) AddRequestTransform(fn func(io.Writer) io.WriteCloser) {
+	opt.RequestTransforms = append(opt.RequestTransforms, fn)
+}
+
+// AddResponseInterceptor adds a function called with each response once its headers are received,
+// including redirect responses, i.e.: to reject a response over a size threshold. The body has not been
+// read. Interceptors run in the order they were added and an error aborts the request and is returned to
+// the caller.
+func (opt *Options) AddResponseInterceptor(fn func(*http.Response) error) {
+	opt.ResponseInterceptors = append(opt.ResponseInterceptors, fn)
+}
+
+// AddResponseTransform adds a function which wraps the response body in another reader, i.e.: to decrypt
+// a body encrypted at rest or transcode it. Transforms are applied in the order they were added, after
+// the body is decompressed, so the Writer, Body, Stream and any checksum see the transformed content.
+// A returned reader which is an io.Closer is closed once the body has been read.
+// An error aborts the request and is returned to the caller.
+func (opt *Options) AddResponseTransform(fn func(io.Reader) (io.Reader, error)) {
+	opt.ResponseTransforms = append(opt.ResponseTransforms, fn)
+}
+
+// TransformEncoding decodes a response received with the from Content-Encoding and re-encodes it with
+// the to compression before it is written, i.e.: to store a gzip response as brotli. A response with
+// any other encoding is written decoded, as usual. Response.DecodedLength reports the decoded size,
+// while Length reports the size of the re-encoded body.
+func (opt *Options) TransformEncoding(from, to CompressionType) {
+	opt.TransformFrom = from
+	opt.TransformTo = to
+}
+
+// SetMaxDownloadBytes stops reading the response body once n decoded bytes have been read, i.e.: to fetch
+// the start of a document to classify it. This is not an error: the partial body is returned and
+// Response.Truncated is set when more of the body remained. The connection is closed rather than reused.
+// The content length and checksum checks are skipped for a truncated body.
+func (opt *Options) SetMaxDownloadBytes(n int64) {
+	opt.MaxDownloadBytes = n
+}
+
+// EnableResume writes the response body to the file and resumes an interrupted download. If the file
+// already exists, a Range request asks for the rest of it. A 206 Partial Content response is appended to
+// the file, while a 200 OK from a server which ignores the Range replaces it. Any other response is kept
+// in the Response.Body and the file is left untouched. Download progress reports against the size of the
+// whole file. Compressed responses cannot be resumed, so do not combine this with AcceptCompression.
+func (opt *Options) EnableResume(filename string) {
+	opt.ResumeFile = filename
+}
+
+// AutoResumeOnError continues downloading the body of a GET with a Range request for the rest of it when the
+// connection fails part way, rather than failing the request. Compressed bodies are not resumed.
+func (opt *Options) AutoResumeOnError() {
+	opt.AutoResume = true
+}
+
+// SetMaxResponseSize guards against a server sending an unbounded body, whether it is buffered, written
+// to a Writer or streamed. Once more than n bytes of the decoded body have been read, the request fails
+// with ErrResponseTooLarge and nothing more is written. Decoded bytes are counted, so a small compressed
+// body which expands to a huge one is caught. Use SetMaxDownloadBytes to truncate a body without error.
+func (opt *Options) SetMaxResponseSize(n int64) {
+	opt.MaxResponseSize = n
+}
+
+// TreatAsRedirect follows responses with any of the status codes as a redirect when they have a Location
+// header, i.e.: a legacy server which responds 200 with a Location. They are followed as a 302 Found,
+// so the redirect limit, loop detection and PreserveMethodOnRedirect apply.
+func (opt *Options) TreatAsRedirect(codes ...int) {
+	opt.RedirectCodes = append(opt.RedirectCodes, codes...)
+}
+
+// SetFraming sets how Subscribe splits the streamed response in to messages. The default is newline framing.
+func (opt *Options) SetFraming(framing FramingType) {
+	opt.Framing = framing
+}
+
+// SetPaginationCursor sets the func(response.Response) (nextURL string, done bool) which GetAll uses to find
+// the next page, i.e.: from a cursor in the response body. The request package cannot refer to the response
+// package, so the cursor is checked when GetAll is called. The default follows the Link header.
+func (opt *Options) SetPaginationCursor(cursor any) {
+	opt.PaginationCursor = cursor
+}
+
+// SetMaxMessageSize sets the largest message, in bytes, Subscribe reads before failing with ErrMessageTooLarge.
+// This stops a corrupt or hostile length prefix, or a stream without line feeds, from exhausting memory.
+// The default is 16 MiB.
+func (opt *Options) SetMaxMessageSize(n int) {
+	opt.MaxMessageSize = n
+}
+
 func (opt *Options) Merge(src Options) {
-	// Merge headers
+	// Merge headers. Header keys are case insensitive, so X-Api-Key replaces X-API-Key
 	for _, sh := range src.Headers {
 		found := false
 		for i, th := range opt.Headers {
-			if th.Key == sh.Key {
+			if strings.EqualFold(th.Key, sh.Key) {
 				opt.Headers[i] = sh
 				found = true
 				break
@@ -142,6 +693,21 @@ func (opt *Options) Merge(src Options) {
 		}
 	}
 
+	// Merge header functions
+	for _, sh := range src.HeaderFuncs {
+		found := false
+		for i, th := range opt.HeaderFuncs {
+			if strings.EqualFold(th.Key, sh.Key) {
+				opt.HeaderFuncs[i] = sh
+				found = true
+				break
+			}
+		}
+		if !found {
+			opt.HeaderFuncs = append(opt.HeaderFuncs, sh)
+		}
+	}
+
 	// Merge cookies
 	for _, sc := range src.Cookies {
 		found := false
@@ -157,6 +723,26 @@ func (opt *Options) Merge(src Options) {
 		}
 	}
 
+	// Merge query parameters. Values are appended to a copy so a shared map is not modified
+	if len(src.QueryParams) > 0 {
+		params := url.Values{}
+		for key, values := range opt.QueryParams {
+			params[key] = append([]string(nil), values...)
+		}
+		for key, values := range src.QueryParams {
+			params[key] = append(params[key], values...)
+		}
+		opt.QueryParams = params
+	}
+
+	// An allowlist which is already set is kept, so the source cannot widen it
+	if len(opt.AllowedHosts) == 0 {
+		opt.AllowedHosts = append([]string(nil), src.AllowedHosts...)
+	}
+	if len(opt.AllowedSchemes) == 0 {
+		opt.AllowedSchemes = append([]string(nil), src.AllowedSchemes...)
+	}
+
 	// Merge other fields, source takes priority if not empty
 	if src.UniqueIdentifier != "" {
 		opt.UniqueIdentifier = src.UniqueIdentifier
@@ -164,12 +750,30 @@ func (opt *Options) Merge(src Options) {
 	if src.Compression != "" {
 		opt.Compression = src.Compression
 	}
+	if src.CompressionLevel != 0 {
+		opt.CompressionLevel = src.CompressionLevel
+	}
 	if src.UserAgent != "" {
 		opt.UserAgent = src.UserAgent
 	}
 	if src.ProtocolScheme != "" {
 		opt.ProtocolScheme = src.ProtocolScheme
 	}
+	if src.ConnectTimeout != 0 {
+		opt.ConnectTimeout = src.ConnectTimeout
+	}
+	if src.ExpectContinueTimeout != 0 {
+		opt.ExpectContinueTimeout = src.ExpectContinueTimeout
+	}
+	if src.Timeout != 0 {
+		opt.Timeout = src.Timeout
+	}
+	if src.IdempotencyKey != "" {
+		opt.IdempotencyKey = src.IdempotencyKey
+	}
+	if src.PreCompressed != "" {
+		opt.PreCompressed = src.PreCompressed
+	}
 
 	// DisableRedirect is a boolean, so we always take the source value
 	opt.DisableRedirect = src.DisableRedirect
@@ -177,4 +781,165 @@ func (opt *Options) Merge(src Options) {
 	if src.Writer != nil {
 		opt.Writer = src.Writer
 	}
+
+	if src.BodyStream != nil {
+		opt.BodyStream = src.BodyStream
+		opt.BodyRewind = src.BodyRewind
+	}
+	if src.BodyReaderAt != nil {
+		opt.BodyReaderAt = src.BodyReaderAt
+		opt.BodyReaderAtSize = src.BodyReaderAtSize
+	}
+
+	if src.CompressedCopy != "" {
+		opt.CompressedCopy = src.CompressedCopy
+	}
+
+	if src.OnDownloadProgress != nil {
+		opt.OnDownloadProgress = src.OnDownloadProgress
+	}
+	if src.WireProgress {
+		opt.WireProgress = src.WireProgress
+	}
+	if src.UploadRateLimit != 0 {
+		opt.UploadRateLimit = src.UploadRateLimit
+	}
+	if src.DownloadRateLimit != 0 {
+		opt.DownloadRateLimit = src.DownloadRateLimit
+	}
+	if src.DisableTransportCompression {
+		opt.DisableTransportCompression = src.DisableTransportCompression
+	}
+	if src.MaxRedirects != 0 {
+		opt.MaxRedirects = src.MaxRedirects
+	}
+	if src.TLSServerName != "" {
+		opt.TLSServerName = src.TLSServerName
+	}
+	if src.InsecureSkipVerify {
+		opt.InsecureSkipVerify = src.InsecureSkipVerify
+	}
+	if len(src.ClientCertificates) > 0 {
+		opt.ClientCertificates = src.ClientCertificates
+	}
+	if src.RootCAs != nil {
+		opt.RootCAs = src.RootCAs
+	}
+	if src.MinTLSVersion != 0 {
+		opt.MinTLSVersion = src.MinTLSVersion
+	}
+	if src.TLSSessionCache {
+		opt.TLSSessionCache = src.TLSSessionCache
+	}
+	if src.Proxy != nil {
+		opt.Proxy = src.Proxy
+	}
+	if src.ChecksumTrailer != "" {
+		opt.ChecksumTrailer = src.ChecksumTrailer
+		opt.ChecksumAlgorithm = src.ChecksumAlgorithm
+	}
+	if src.ExpectedRedirect != "" {
+		opt.ExpectedRedirect = src.ExpectedRedirect
+	}
+	if src.StreamOutput {
+		opt.StreamOutput = src.StreamOutput
+	}
+	if src.ChecksumHeader != "" {
+		opt.ChecksumHeader = src.ChecksumHeader
+		opt.ChecksumAlgorithm = src.ChecksumAlgorithm
+	}
+	if src.PreserveMethodOnRedirect {
+		opt.PreserveMethodOnRedirect = src.PreserveMethodOnRedirect
+	}
+	if src.OnUploadProgress != nil {
+		opt.OnUploadProgress = src.OnUploadProgress
+	}
+	if src.LenientDecoding {
+		opt.LenientDecoding = src.LenientDecoding
+	}
+	if src.Logger != nil {
+		opt.Logger = src.Logger
+	}
+	opt.LogFields = append(opt.LogFields, src.LogFields...)
+	if src.Framing != FramingNewline {
+		opt.Framing = src.Framing
+	}
+	if src.MaxMessageSize != 0 {
+		opt.MaxMessageSize = src.MaxMessageSize
+	}
+	opt.RedirectCodes = append(opt.RedirectCodes, src.RedirectCodes...)
+	if src.LogTimeFormat != "" {
+		opt.LogTimeFormat = src.LogTimeFormat
+	}
+	if src.LogUTC {
+		opt.LogUTC = src.LogUTC
+	}
+	if src.MaxResponseSize != 0 {
+		opt.MaxResponseSize = src.MaxResponseSize
+	}
+	if src.ResumeFile != "" {
+		opt.ResumeFile = src.ResumeFile
+	}
+	if src.AutoResume {
+		opt.AutoResume = src.AutoResume
+	}
+	if src.MaxDownloadBytes != 0 {
+		opt.MaxDownloadBytes = src.MaxDownloadBytes
+	}
+	if src.TransformTo != CompressionNone {
+		opt.TransformFrom = src.TransformFrom
+		opt.TransformTo = src.TransformTo
+	}
+	opt.RequestInterceptors = append(opt.RequestInterceptors, src.RequestInterceptors...)
+	opt.RequestTransforms = append(opt.RequestTransforms, src.RequestTransforms...)
+	opt.ResponseInterceptors = append(opt.ResponseInterceptors, src.ResponseInterceptors...)
+	opt.ResponseTransforms = append(opt.ResponseTransforms, src.ResponseTransforms...)
+	if src.ForceChunked {
+		opt.ForceChunked = src.ForceChunked
+	}
+	if src.LocalAddr != nil {
+		opt.LocalAddr = src.LocalAddr
+	}
+	if src.RequireJSON {
+		opt.RequireJSON = src.RequireJSON
+	}
+	if src.ValidateUTF8 {
+		opt.ValidateUTF8 = src.ValidateUTF8
+	}
+	if src.SpillThreshold != 0 {
+		opt.SpillThreshold = src.SpillThreshold
+		opt.SpillDir = src.SpillDir
+	}
+	if src.DefaultContentType != "" {
+		opt.DefaultContentType = src.DefaultContentType
+	}
+	if src.StrictContentLength {
+		opt.StrictContentLength = src.StrictContentLength
+	}
+	if src.RelayHeaderFilter != nil {
+		opt.RelayHeaderFilter = src.RelayHeaderFilter
+	}
+	if src.OnFileUploadProgress != nil {
+		opt.OnFileUploadProgress = src.OnFileUploadProgress
+	}
+	if src.TokenProvider != nil {
+		opt.TokenProvider = src.TokenProvider
+	}
+	if src.OnRequestStart != nil {
+		opt.OnRequestStart = src.OnRequestStart
+	}
+	if src.OnRequestComplete != nil {
+		opt.OnRequestComplete = src.OnRequestComplete
+	}
+	if src.PaginationCursor != nil {
+		opt.PaginationCursor = src.PaginationCursor
+	}
+
+	if src.RedirectHistory {
+		opt.RedirectHistory = src.RedirectHistory
+	}
+
+	if src.Clock != nil {
+		opt.Clock = src.Clock
+	}
 }
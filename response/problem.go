@@ -0,0 +1,89 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+)
+
+// ProblemDetails is an RFC 7807 problem details object, sent as application/problem+json.
+// It implements error so it can be returned, or unwrapped from a *StatusError, with errors.As.
+type ProblemDetails struct {
+	Type       string         // URI reference identifying the problem type, about:blank if not sent
+	Title      string         // Short summary of the problem type
+	Status     int            // HTTP status code set by the server
+	Detail     string         // Explanation specific to this occurrence of the problem
+	Instance   string         // URI reference identifying this occurrence of the problem
+	Extensions map[string]any // Any other members of the problem details object
+}
+
+func (p *ProblemDetails) Error() string {
+	msg := p.Title
+	if msg == "" {
+		msg = p.Type
+	}
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	return msg
+}
+
+// ProblemDetails parses an application/problem+json response body in to its standard fields.
+// Members which are not part of RFC 7807 are kept in Extensions.
+// An error is returned if the body is not problem details or was written to a Writer.
+func (r *Response) ProblemDetails() (*ProblemDetails, error) {
+	if r.Options.Writer != nil {
+		return nil, errors.New("response body was written to the Writer and not retained")
+	}
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "application/problem+json" {
+		return nil, &ContentTypeError{Expected: "application/problem+json", ContentType: contentType, Snippet: snippet(r.Bytes())}
+	}
+	return parseProblemDetails(r.Bytes())
+}
+
+// parseProblemDetails decodes a problem details object. Standard members of the wrong type are ignored,
+// as required by RFC 7807.
+func parseProblemDetails(body []byte) (*ProblemDetails, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil, fmt.Errorf("unable to decode problem details: %w", err)
+	}
+	p := &ProblemDetails{}
+	for name, raw := range members {
+		switch name {
+		case "type":
+			json.Unmarshal(raw, &p.Type)
+		case "title":
+			json.Unmarshal(raw, &p.Title)
+		case "status":
+			json.Unmarshal(raw, &p.Status)
+		case "detail":
+			json.Unmarshal(raw, &p.Detail)
+		case "instance":
+			json.Unmarshal(raw, &p.Instance)
+		default:
+			var v any
+			if json.Unmarshal(raw, &v) == nil {
+				if p.Extensions == nil {
+					p.Extensions = make(map[string]any)
+				}
+				p.Extensions[name] = v
+			}
+		}
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	return p, nil
+}
+
+// snippet returns the start of a body for use in an error.
+func snippet(body []byte) string {
+	if len(body) > 256 {
+		body = body[:256]
+	}
+	return string(body)
+}
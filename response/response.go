@@ -3,12 +3,28 @@ package response
 import (
 	"bytes"
 	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/caelisco/http-client/request"
+	"gopkg.in/yaml.v3"
 )
 
+// recordSeparator is the ASCII RS character which starts each record of an application/json-seq body
+const recordSeparator = 0x1E
+
 // Response represents the HTTP response along with additional details.
 type Response struct {
 	UniqueIdentifier string                  // Internally generated UUID for the request
@@ -22,18 +38,31 @@ type Response struct {
 	Status           string                  // Status of the HTTP response
 	StatusCode       int                     // HTTP status code of the response
 	Proto            string                  // HTTP protocol used
+	ProtoMajor       int                     // Major version of the HTTP protocol, i.e.: 1 for HTTP/1.1
+	ProtoMinor       int                     // Minor version of the HTTP protocol, i.e.: 1 for HTTP/1.1
 	Header           http.Header             // HTTP headers of the response
 	ContentLength    int64                   // Content length from the response
+	DecodedLength    int64                   // Length of the decoded body, before any TransformEncoding
 	TransferEncoding []string                // Transfer encoding of the response
-	CompressionType  request.CompressionType // Type of compression
+	CompressionType  request.CompressionType // Type of compression used for the request payload
+	ResponseEncoding request.CompressionType // Content-Encoding of the body as it was received, before it was decompressed
 	Uncompressed     bool                    // Was the response compressed - https://pkg.go.dev/net/http#Response.Uncompressed
 	Cookies          []*http.Cookie          // Cookies received in the response
 	AccessTime       time.Duration           // Time taken to complete the request
+	TimeToFirstByte  time.Duration           // Time from sending the request to receiving the first byte of the response
+	ServerTiming     []ServerTimingMetric    // Metrics sent by the server in the Server-Timing header
 	Body             bytes.Buffer            // Response body as bytes
+	Stream           io.ReadCloser           // Unread response body when StreamOutput is set. The caller must close it
 	Error            error                   // Error encountered during the request
 	TLS              *tls.ConnectionState    // TLS connection state
+	TLSResumed       bool                    // The TLS handshake resumed a cached session
 	Redirected       bool                    // Was the request redirected
 	Location         string                  // If redirected, what was the location
+	SentHeaders      http.Header             // Headers written on the wire for the final request, after any redirects
+	SentRequestURL   string                  // URL of the final request, after any redirects
+	Redirects        []Response              // Intermediate redirect responses when RedirectHistory is enabled
+	SpillFile        string                  // Temporary file holding the body when it exceeded the spill threshold
+	Truncated        bool                    // The body was cut short by MaxDownloadBytes
 }
 
 func New(url string, method string, payload []byte, opt request.Options) Response {
@@ -47,35 +76,375 @@ func New(url string, method string, payload []byte, opt request.Options) Respons
 	}
 }
 
+// AddRedirect records an intermediate redirect response in Redirects.
+// Only the status, headers, cookies and Location are kept. The body is left to the
+// http.Client which drains and closes it before following the redirect.
+func (r *Response) AddRedirect(resp *http.Response) {
+	r.Redirects = append(r.Redirects, Redirect(resp))
+}
+
+// Redirect returns the Response of an intermediate redirect, with the status, headers, cookies and Location.
+func Redirect(resp *http.Response) Response {
+	return Response{
+		URL:              resp.Request.URL.String(),
+		Method:           resp.Request.Method,
+		Status:           resp.Status,
+		StatusCode:       resp.StatusCode,
+		Proto:            resp.Proto,
+		ProtoMajor:       resp.ProtoMajor,
+		ProtoMinor:       resp.ProtoMinor,
+		Header:           resp.Header,
+		ContentLength:    resp.ContentLength,
+		TransferEncoding: resp.TransferEncoding,
+		Cookies:          resp.Cookies(),
+		TLS:              resp.TLS,
+		Redirected:       true,
+		Location:         resp.Header.Get("Location"),
+	}
+}
+
 // Bytes is a helper function to get the underlying bytes.Buffer []byte
+// If the body was spilled to a temporary file, it is read back from the file.
 func (r *Response) Bytes() []byte {
+	if r.SpillFile != "" {
+		b, err := os.ReadFile(r.SpillFile)
+		if err != nil {
+			return nil
+		}
+		return b
+	}
 	return r.Body.Bytes()
 }
 
 // String is a helper function to get the underlying bytes.Buffer string
+// If the body was spilled to a temporary file, it is read back from the file.
 func (r *Response) String() string {
+	if r.SpillFile != "" {
+		return string(r.Bytes())
+	}
 	return r.Body.String()
 }
 
+// StatusLine returns the status line of the response as it would be written on the wire, i.e.: HTTP/1.1 200 OK
+// An empty string is returned if no response was received.
+func (r *Response) StatusLine() string {
+	if r.StatusCode == 0 {
+		return ""
+	}
+	status := r.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode))
+	}
+	return r.Proto + " " + status
+}
+
+// JSON decodes the JSON response body in to v. An error is returned if the body is empty, the
+// Content-Type is not JSON, or the body was written to a Writer and was not retained.
+func (r *Response) JSON(v any) error {
+	if r.Options.Writer != nil {
+		return errors.New("response body was written to the Writer and not retained, decode it from the Writer instead")
+	}
+	body := r.Bytes()
+	if len(body) == 0 {
+		return fmt.Errorf("response body from %s is empty", r.URL)
+	}
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		snippet := body
+		if len(snippet) > 256 {
+			snippet = snippet[:256]
+		}
+		return &ContentTypeError{Expected: "application/json", ContentType: contentType, Snippet: string(snippet)}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("unable to decode JSON response from %s: %w", r.URL, err)
+	}
+	return nil
+}
+
+// Unmarshal decodes the response body in to v based on the Content-Type. JSON, XML and YAML media types,
+// including those with a +json, +xml or +yaml suffix, are supported. A missing or generic Content-Type,
+// such as text/plain or application/octet-stream, is decoded as JSON. Any other media type returns an error.
+func (r *Response) Unmarshal(v any) error {
+	if r.Options.Writer != nil {
+		return errors.New("response body was written to the Writer and not retained, decode it from the Writer instead")
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var err error
+	switch {
+	case mediaType == "", mediaType == "text/plain", mediaType == "application/octet-stream",
+		mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		err = json.Unmarshal(r.Bytes(), v)
+	case mediaType == "application/xml", mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		err = xml.Unmarshal(r.Bytes(), v)
+	case mediaType == "application/yaml", mediaType == "application/x-yaml", mediaType == "text/yaml",
+		mediaType == "text/x-yaml", strings.HasSuffix(mediaType, "+yaml"):
+		err = yaml.Unmarshal(r.Bytes(), v)
+	default:
+		return fmt.Errorf("unable to unmarshal response from %s: unsupported media type %s", r.URL, mediaType)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to decode %s response from %s: %w", mediaType, r.URL, err)
+	}
+	return nil
+}
+
+// DecodeJSONSeq splits an application/json-seq (RFC 7464) body in to its JSON documents and calls fn
+// with each of them in order. Every record starts with the ASCII record separator (0x1E) and ends with
+// a line feed. Empty records are skipped. Decoding stops at the first error returned by fn.
+func (r *Response) DecodeJSONSeq(fn func(raw json.RawMessage) error) error {
+	for _, record := range bytes.Split(r.Bytes(), []byte{recordSeparator}) {
+		record = bytes.TrimSpace(record)
+		if len(record) == 0 {
+			continue
+		}
+		if !json.Valid(record) {
+			return fmt.Errorf("invalid JSON text in json-seq record: %q", record)
+		}
+		if err := fn(json.RawMessage(record)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buffered reports if the body was read in to the Response, rather than written to a Writer or streamed.
+func (r *Response) buffered() bool {
+	return r.Options.Writer == nil && !r.Options.StreamOutput && r.Stream == nil
+}
+
+// ReadSeeker returns an io.ReadSeeker over the response body, so the body can be re-read or seeked,
+// i.e.: inspect a prefix to detect the format and then parse from the start.
+// It is only available when the body is buffered and returns nil when a Writer was used or the body was streamed.
+func (r *Response) ReadSeeker() io.ReadSeeker {
+	if !r.buffered() {
+		return nil
+	}
+	return bytes.NewReader(r.Bytes())
+}
+
+// Reader returns a new io.Reader over the buffered response body. Each call returns an independent
+// reader positioned at the start of the body and reading does not consume the body, so it can be
+// handed to several consumers, i.e.: fan-out processing of one response in separate goroutines.
+// It returns nil when a Writer was used or the body was streamed.
+func (r *Response) Reader() io.Reader {
+	if !r.buffered() {
+		return nil
+	}
+	return bytes.NewReader(r.Bytes())
+}
+
+// MultipartReader returns a *multipart.Reader over a buffered multipart response body,
+// using the boundary from the Content-Type header.
+func (r *Response) MultipartReader() (*multipart.Reader, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("response is not multipart: %s", mediaType)
+	}
+	if params["boundary"] == "" {
+		return nil, errors.New("multipart response has no boundary")
+	}
+	return multipart.NewReader(bytes.NewReader(r.Bytes()), params["boundary"]), nil
+}
+
+// SaveMultipartParts writes each part of a multipart response to a file under dir and returns the
+// paths written. Files are named from the filename in the Content-Disposition of each part, or
+// part-N when there is none. Only the base of the filename is used so a part cannot escape dir.
+func (r *Response) SaveMultipartParts(dir string) ([]string, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for i := 1; ; i++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return paths, nil
+		}
+		if err != nil {
+			return paths, err
+		}
+		name := filepath.Base(part.FileName())
+		if name == "." || name == "/" || name == ".." {
+			name = fmt.Sprintf("part-%d", i)
+		}
+		path := filepath.Join(dir, name)
+		if err = saveFile(path, part); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+}
+
+func saveFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func (r *Response) Length() int {
+	if r.SpillFile != "" {
+		fi, err := os.Stat(r.SpillFile)
+		if err != nil {
+			return 0
+		}
+		return int(fi.Size())
+	}
 	return r.Body.Len()
 }
 
-func (r *Response) PopulateResponse(resp *http.Response, start time.Time) {
+// PopulateResponse copies the status, headers and connection details of resp. The original URL is the URL
+// of the request that was sent, before any redirects were followed.
+func (r *Response) PopulateResponse(resp *http.Response, original *url.URL, start time.Time) {
 	r.Status = resp.Status
 	r.StatusCode = resp.StatusCode
 	r.Proto = resp.Proto
+	r.ProtoMajor = resp.ProtoMajor
+	r.ProtoMinor = resp.ProtoMinor
 	r.Header = resp.Header
+	r.ContentLength = resp.ContentLength
 	r.TransferEncoding = resp.TransferEncoding
 	// store cookies from the response
 	r.Cookies = resp.Cookies()
-	r.AccessTime = time.Since(start)
+	r.AccessTime = r.Options.Now().Sub(start)
 	r.Uncompressed = resp.Uncompressed
+	r.ResponseEncoding = Encoding(resp)
 	r.TLS = resp.TLS
+	r.ServerTiming = parseServerTiming(resp.Header)
 
-	// Check for redirects
-	if len(resp.Request.URL.String()) != len(r.URL) {
+	// resp.Request is the last request in the redirect chain
+	if original != nil && resp.Request != nil && resp.Request.URL.String() != original.String() {
 		r.Redirected = true
 		r.Location = resp.Request.URL.String()
 	}
+	if resp.Request != nil {
+		r.SentHeaders = sentHeaders(resp.Request)
+		r.SentRequestURL = resp.Request.URL.String()
+	}
+}
+
+// sentHeaders returns the headers of the request, along with the Content-Length or Transfer-Encoding
+// which the transport writes from the length of the body. Other headers added by the transport, such as
+// Accept-Encoding, are not included. The client replaces them with the headers traced as they were written.
+func sentHeaders(req *http.Request) http.Header {
+	header := req.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if req.ContentLength > 0 {
+		header.Set("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+	} else if req.Body != nil && req.Body != http.NoBody && req.ContentLength <= 0 && req.ProtoAtLeast(1, 1) {
+		header.Set("Transfer-Encoding", "chunked")
+	}
+	return header
+}
+
+// Encoding returns the Content-Encoding of the response body as it was received, before it was decompressed.
+func Encoding(resp *http.Response) request.CompressionType {
+	encoding := request.CompressionType(strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))))
+	// The transport removes the Content-Encoding when it transparently decompresses a gzip body
+	if encoding == request.CompressionNone && resp.Uncompressed {
+		encoding = request.CompressionGzip
+	}
+	return encoding
+}
+
+// ContentLengthError is returned when EnforceContentLength is set and the response body is shorter
+// than the Content-Length declared by the server.
+type ContentLengthError struct {
+	Expected int64 // The Content-Length declared by the server
+	Actual   int64 // The number of bytes received
+}
+
+func (e *ContentLengthError) Error() string {
+	return fmt.Sprintf("response body length %d does not match the Content-Length %d", e.Actual, e.Expected)
+}
+
+// ContentTypeError is returned when the response does not have the expected Content-Type,
+// i.e.: an HTML login page returned instead of JSON. It includes the start of the body to help
+// identify what the server sent.
+type ContentTypeError struct {
+	Expected    string // The expected media type
+	ContentType string // The Content-Type sent by the server
+	Snippet     string // The start of the response body
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("expected %s response but received %q: %s", e.Expected, e.ContentType, e.Snippet)
+}
+
+// UTF8Error is returned when RequireUTF8 is set and the response body is not valid UTF-8.
+type UTF8Error struct {
+	Offset int64 // Offset of the first invalid byte in the decoded body
+}
+
+func (e *UTF8Error) Error() string {
+	return fmt.Sprintf("response body is not valid UTF-8: invalid byte at offset %d", e.Offset)
+}
+
+// ChecksumError is returned when the checksum of the response body does not match the digest
+// sent by the server, or the server did not send the digest.
+type ChecksumError struct {
+	Algorithm string // The checksum algorithm, i.e.: sha256
+	Expected  string // The digest sent by the server
+	Actual    string // The hex encoded digest of the body received
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("%s checksum was not sent by the server", e.Algorithm)
+	}
+	return fmt.Sprintf("%s checksum mismatch: expected %s, received %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// StatusError is returned when a response has a status code outside of the 2xx range
+// where a successful response was expected. It includes the start of the body.
+type StatusError struct {
+	StatusCode int             // HTTP status code of the response
+	Status     string          // Status of the HTTP response
+	Snippet    string          // The start of the response body
+	Problem    *ProblemDetails // Problem details sent as application/problem+json, if any
+}
+
+func (e *StatusError) Error() string {
+	if e.Problem != nil {
+		return fmt.Sprintf("unexpected status %s: %s", e.Status, e.Problem)
+	}
+	if e.Snippet == "" {
+		return fmt.Sprintf("unexpected status %s", e.Status)
+	}
+	return fmt.Sprintf("unexpected status %s: %s", e.Status, e.Snippet)
+}
+
+// Unwrap returns the problem details, so they can be retrieved with errors.As.
+func (e *StatusError) Unwrap() error {
+	if e.Problem == nil {
+		return nil
+	}
+	return e.Problem
+}
+
+// RedirectError is returned when ExpectRedirectTo is set and the request was not redirected
+// to the expected URL.
+type RedirectError struct {
+	Expected string // The expected URL
+	First    string // The first redirect target, empty if the request was not redirected
+	Final    string // The final URL of the request
+}
+
+func (e *RedirectError) Error() string {
+	if e.First == "" {
+		return fmt.Sprintf("expected a redirect to %s but the request was not redirected", e.Expected)
+	}
+	return fmt.Sprintf("expected a redirect to %s but was redirected to %s, ending at %s", e.Expected, e.First, e.Final)
 }
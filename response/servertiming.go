@@ -0,0 +1,89 @@
+package response
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerTimingMetric is a metric sent by the server in the Server-Timing header.
+// See https://www.w3.org/TR/server-timing/
+type ServerTimingMetric struct {
+	Name        string        // Name of the metric, i.e.: db or cache
+	Duration    time.Duration // Value of the dur parameter, zero if it was not sent
+	Description string        // Value of the desc parameter
+}
+
+// parseServerTiming parses every Server-Timing header in to its metrics.
+// Metrics are separated by commas and their parameters by semicolons. Parameter values may be quoted strings.
+// Only the first dur and desc parameter of a metric are used, as required by the specification,
+// and parameters which cannot be parsed are ignored.
+func parseServerTiming(header http.Header) []ServerTimingMetric {
+	var metrics []ServerTimingMetric
+	for _, value := range header.Values("Server-Timing") {
+		for _, entry := range splitQuoted(value, ',') {
+			params := splitQuoted(entry, ';')
+			name := strings.TrimSpace(params[0])
+			if name == "" {
+				continue
+			}
+			metric := ServerTimingMetric{Name: name}
+			var hasDur, hasDesc bool
+			for _, param := range params[1:] {
+				key, val, _ := strings.Cut(param, "=")
+				key = strings.ToLower(strings.TrimSpace(key))
+				val = unquote(strings.TrimSpace(val))
+				switch {
+				case key == "dur" && !hasDur:
+					hasDur = true
+					if ms, err := strconv.ParseFloat(val, 64); err == nil {
+						metric.Duration = time.Duration(ms * float64(time.Millisecond))
+					}
+				case key == "desc" && !hasDesc:
+					hasDesc = true
+					metric.Description = val
+				}
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics
+}
+
+// splitQuoted splits s at each sep which is not inside a quoted string.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	var quoted, escaped bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote removes the quotes and escapes from a quoted string. Any other value is returned as is.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	var b strings.Builder
+	s = s[1 : len(s)-1]
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
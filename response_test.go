@@ -0,0 +1,128 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caelisco/http-client/request"
+	"github.com/caelisco/http-client/response"
+)
+
+func TestBodyReadersOnlyForBufferedResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	resp, err := Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, reader := range []io.Reader{resp.Reader(), resp.ReadSeeker()} {
+		b, _ := io.ReadAll(reader)
+		if string(b) != "body" {
+			t.Fatalf("expected a reader over the buffered body, got %q", b)
+		}
+	}
+
+	opt := request.NewOptions()
+	opt.SetStreamOutput()
+	resp, err = Get(server.URL, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Stream.Close()
+	if resp.Reader() != nil || resp.ReadSeeker() != nil {
+		t.Fatal("expected no reader over the body of a streamed response")
+	}
+}
+
+// getWithContentType performs a GET to a server which responds with the body and Content-Type.
+func getWithContentType(t *testing.T, contentType string, body string) Response {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	resp, err := Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+type item struct {
+	Name string `json:"name" xml:"name" yaml:"name"`
+}
+
+func TestUnmarshalByContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json; charset=utf-8", `{"name":"widget"}`},
+		{"application/vnd.api+json", `{"name":"widget"}`},
+		{"application/xml", `<item><name>widget</name></item>`},
+		{"application/yaml", "name: widget\n"},
+		{"text/x-yaml", "name: widget\n"},
+		{"application/vnd.spec+yaml", "name: widget\n"},
+		{"text/plain", `{"name":"widget"}`},
+	}
+	for _, test := range tests {
+		r := getWithContentType(t, test.contentType, test.body)
+		var v item
+		if err := r.Unmarshal(&v); err != nil {
+			t.Fatalf("%s: %v", test.contentType, err)
+		}
+		if v.Name != "widget" {
+			t.Fatalf("%s: expected widget, got %q", test.contentType, v.Name)
+		}
+	}
+}
+
+func TestUnmarshalRejectsUnsupportedMediaTypes(t *testing.T) {
+	r := getWithContentType(t, "image/png", "not an image")
+	var v item
+	err := r.Unmarshal(&v)
+	if err == nil || !strings.Contains(err.Error(), "unsupported media type image/png") {
+		t.Fatalf("expected an unsupported media type error, got %v", err)
+	}
+
+	r = getWithContentType(t, "application/yaml", "name: [unterminated")
+	if err = r.Unmarshal(&v); err == nil {
+		t.Fatal("expected an error for invalid YAML")
+	}
+}
+
+func TestRequireUTF8OnlyValidatesBufferedBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("valid \xff invalid"))
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.RequireUTF8()
+	var utf8Err *response.UTF8Error
+	if _, err := Get(server.URL, opt); !errors.As(err, &utf8Err) {
+		t.Fatalf("expected a *response.UTF8Error for a buffered body, got %v", err)
+	}
+	if utf8Err.Offset != 6 {
+		t.Fatalf("expected the invalid byte at offset 6, got %d", utf8Err.Offset)
+	}
+
+	// The body written to a file is left as it was received
+	path := filepath.Join(t.TempDir(), "download.bin")
+	if err := opt.FileWriter(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Get(server.URL, opt); err != nil {
+		t.Fatalf("expected a body written to a file not to be validated, got %v", err)
+	}
+}
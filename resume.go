@@ -0,0 +1,174 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// resumeAttempts is the most times a body is continued with a Range request after the connection fails.
+const resumeAttempts = 3
+
+// resumeState describes where a resumed download starts and the total size of the resource.
+type resumeState struct {
+	start int64 // Bytes already in the file
+	total int64 // Size of the whole resource, -1 if not known
+}
+
+// resumeOffset returns the size of a partially downloaded file, or 0 if it does not exist.
+func resumeOffset(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// openResumeFile opens the file a resumed download is written to. A 206 Partial Content response which
+// starts at offset is appended to the file, while a 200 OK replaces the file. Any other status returns a
+// nil file and leaves the file untouched.
+func openResumeFile(r *http.Response, path string, offset int64) (*os.File, *resumeState, error) {
+	switch r.StatusCode {
+	case http.StatusOK:
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, &resumeState{start: 0, total: r.ContentLength}, nil
+	case http.StatusPartialContent:
+		start, total, err := parseContentRange(r.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, nil, err
+		}
+		if start != offset {
+			return nil, nil, fmt.Errorf("cannot resume download at byte %d: server sent content from byte %d", offset, start)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return f, &resumeState{start: start, total: total}, nil
+	}
+	return nil, nil, nil
+}
+
+// parseContentRange parses a Content-Range header such as bytes 100-199/200, returning the first byte
+// and the total size. The total is -1 when the server sent * as the size.
+func parseContentRange(value string) (int64, int64, error) {
+	rangeSpec, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range: %q", value)
+	}
+	span, size, ok := strings.Cut(rangeSpec, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range: %q", value)
+	}
+	first, _, ok := strings.Cut(span, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range: %q", value)
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range: %q", value)
+	}
+	total := int64(-1)
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid Content-Range: %q", value)
+		}
+	}
+	return start, total, nil
+}
+
+// resumingReader reads a response body and, when the connection fails part way, continues it from where
+// it stopped with a Range request for the rest of the body.
+type resumingReader struct {
+	client    *http.Client
+	req       *http.Request // The request for the body, after any redirects
+	body      io.ReadCloser
+	offset    int64  // Position of the next byte in the resource
+	validator string // ETag or Last-Modified sent as If-Range, so a changed resource is not resumed
+	attempts  int
+}
+
+// newResumingReader returns a reader which resumes the body of r, or nil if the body cannot be resumed.
+func newResumingReader(client *http.Client, r *http.Response) *resumingReader {
+	if r.Request.Method != http.MethodGet || r.Uncompressed || r.Header.Get("Content-Encoding") != "" || r.Header.Get("Accept-Ranges") == "none" {
+		return nil
+	}
+	rr := &resumingReader{client: client, req: r.Request, body: r.Body}
+	switch r.StatusCode {
+	case http.StatusOK:
+	case http.StatusPartialContent:
+		start, _, err := parseContentRange(r.Header.Get("Content-Range"))
+		if err != nil {
+			return nil
+		}
+		rr.offset = start
+	default:
+		return nil
+	}
+	// A weak ETag cannot be used with If-Range
+	if etag := r.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		rr.validator = etag
+	} else {
+		rr.validator = r.Header.Get("Last-Modified")
+	}
+	return rr
+}
+
+func (rr *resumingReader) Read(p []byte) (int, error) {
+	n, err := rr.body.Read(p)
+	rr.offset += int64(n)
+	if err == nil || err == io.EOF || rr.attempts >= resumeAttempts || rr.req.Context().Err() != nil {
+		return n, err
+	}
+	rr.attempts++
+	if rerr := rr.resume(); rerr != nil {
+		return n, err
+	}
+	if n == 0 {
+		return rr.Read(p)
+	}
+	return n, nil
+}
+
+// resume requests the rest of the body from the current offset.
+func (rr *resumingReader) resume() error {
+	req := rr.req.Clone(rr.req.Context())
+	req.Body, req.GetBody, req.ContentLength = nil, nil, 0
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", rr.offset))
+	if rr.validator != "" {
+		req.Header.Set("If-Range", rr.validator)
+	}
+	resp, err := rr.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return fmt.Errorf("cannot resume download: server responded %s", resp.Status)
+	}
+	start, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err == nil && start != rr.offset {
+		err = fmt.Errorf("cannot resume download at byte %d: server sent content from byte %d", rr.offset, start)
+	}
+	if err != nil {
+		resp.Body.Close()
+		return err
+	}
+	rr.body.Close()
+	rr.body = resp.Body
+	return nil
+}
+
+func (rr *resumingReader) Close() error {
+	return rr.body.Close()
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caelisco/http-client/request"
+)
+
+func TestEnableResumeCompletesPartialDownload(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	var rangeHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader = r.Header.Get("Range")
+		http.ServeContent(w, r, "download.txt", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "download.txt")
+	if err := os.WriteFile(path, []byte(content[:400]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opt := request.NewOptions()
+	opt.EnableResume(path)
+	var first, read, total int64 = -1, 0, 0
+	opt.OnDownloadProgress = func(bytesRead, totalBytes int64) {
+		if first < 0 {
+			first = bytesRead
+		}
+		read, total = bytesRead, totalBytes
+	}
+	resp, err := Get(server.URL, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("expected 206 Partial Content, got %d", resp.StatusCode)
+	}
+	if rangeHeader != "bytes=400-" {
+		t.Fatalf("expected a Range for the rest of the file, got %q", rangeHeader)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Fatalf("expected the file to be completed, got %d of %d bytes", len(got), len(content))
+	}
+	// Progress is reported against the whole file, starting from the bytes already downloaded
+	if first < 400 || read != 1000 || total != 1000 {
+		t.Fatalf("expected progress from 400 to 1000 of 1000, got %d to %d of %d", first, read, total)
+	}
+}
+
+func TestAutoResumeOnError(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "" {
+			// Send part of the body and drop the connection
+			conn, buf, _ := w.(http.Hijacker).Hijack()
+			defer conn.Close()
+			fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\nETag: \"v1\"\r\n\r\n%s", len(content), content[:400])
+			buf.Flush()
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+		// The resource at /changed was modified after the first part was sent
+		if r.URL.Path == "/changed" {
+			w.Header().Set("ETag", `"v2"`)
+		} else {
+			w.Header().Set("ETag", `"v1"`)
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	if _, err := Get(server.URL); err == nil {
+		t.Fatal("expected an error for a body cut short without AutoResumeOnError")
+	}
+
+	opt := request.NewOptions()
+	opt.AutoResumeOnError()
+	opt.EnforceContentLength()
+	resp, err := Get(server.URL, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != content {
+		t.Fatalf("expected the whole body, got %d of %d bytes", resp.Length(), len(content))
+	}
+	if len(ranges) != 1 || ranges[0] != `bytes=400- "v1"` {
+		t.Fatalf("expected one Range request for the rest of the body, got %q", ranges)
+	}
+
+	// A resource which changed is not resumed
+	if _, err = Get(server.URL+"/changed", opt); err == nil {
+		t.Fatal("expected an error when the resource changed")
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"bytes"
+	"os"
+)
+
+// spillWriter writes in to a bytes.Buffer until the threshold is exceeded. At that point the buffered
+// content, and everything written after it, is moved to a temporary file in dir.
+type spillWriter struct {
+	buf       *bytes.Buffer
+	threshold int64
+	dir       string
+	file      *os.File
+	err       error
+}
+
+func (w *spillWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.file == nil && int64(w.buf.Len()+len(p)) > w.threshold {
+		f, err := os.CreateTemp(w.dir, "http-client-*")
+		if err != nil {
+			return 0, err
+		}
+		w.file = f
+		// WriteTo drains the buffer so the memory can be released
+		if _, err = w.buf.WriteTo(f); err != nil {
+			w.fail(err)
+			return 0, err
+		}
+	}
+	if w.file != nil {
+		n, err := w.file.Write(p)
+		if err != nil {
+			w.fail(err)
+		}
+		return n, err
+	}
+	return w.buf.Write(p)
+}
+
+// fail removes the temporary file and keeps the error, so no more is written.
+func (w *spillWriter) fail(err error) {
+	w.err = err
+	w.discard()
+}
+
+// Close closes the temporary file if the writer spilled to disk.
+func (w *spillWriter) Close() error {
+	if w.file == nil {
+		return w.err
+	}
+	return w.file.Close()
+}
+
+// discard closes and removes the temporary file, if the writer spilled to disk, i.e.: when the request failed.
+func (w *spillWriter) discard() {
+	if w.file == nil {
+		return
+	}
+	w.file.Close()
+	os.Remove(w.file.Name())
+	w.file = nil
+}
+
+// Name returns the path of the temporary file, or an empty string if the writer did not spill.
+func (w *spillWriter) Name() string {
+	if w.file == nil {
+		return ""
+	}
+	return w.file.Name()
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/caelisco/http-client/request"
+)
+
+func TestSpillFileRemovedWhenRequestFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	opt := request.NewOptions()
+	opt.SetBufferSpillToFile(10, dir)
+	opt.SetMaxResponseSize(50)
+	if _, err := Get(server.URL, opt); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the temporary file to be removed, found %d files", len(entries))
+	}
+}
+
+func TestSpillFileKeptWhenRequestSucceeds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.SetBufferSpillToFile(10, t.TempDir())
+	resp, err := Get(server.URL, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.SpillFile == "" || resp.Length() != 100 {
+		t.Fatalf("expected the body to be spilled to a file, got %q of %d bytes", resp.SpillFile, resp.Length())
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"io"
+	"sync"
+)
+
+// releaser collects the functions which release the resources held by a request.
+// They are run in reverse order, as deferred calls would be.
+type releaser []func()
+
+func (r *releaser) add(fn func()) {
+	*r = append(*r, fn)
+}
+
+func (r releaser) run() {
+	for i := len(r) - 1; i >= 0; i-- {
+		r[i]()
+	}
+}
+
+// streamBody is the response body handed to the caller when RequestOptions.StreamOutput is set.
+// Closing it closes the underlying response body and releases the resources held by the request.
+type streamBody struct {
+	reader  io.Reader
+	release releaser
+	once    sync.Once
+}
+
+func (s *streamBody) Read(p []byte) (int, error) {
+	return s.reader.Read(p)
+}
+
+func (s *streamBody) Close() error {
+	s.once.Do(s.release.run)
+	return nil
+}
+
+// bodyStream is the pipe a RequestOptions.BodyStream is written through as the request body.
+type bodyStream struct {
+	opt  RequestOptions
+	pipe *io.PipeReader
+	done <-chan struct{}
+}
+
+func newBodyStream(opt RequestOptions) *bodyStream {
+	s := &bodyStream{opt: opt}
+	s.pipe, s.done = streamPayload(opt)
+	return s
+}
+
+// stop closes the pipe, which stops the stream if the body has not been sent.
+// A body which can be replayed is only sent again once the stream has stopped reading it.
+func (s *bodyStream) stop() {
+	s.pipe.CloseWithError(errBodyNotSent)
+	if s.opt.BodyRewind != nil {
+		<-s.done
+	}
+}
+
+// replay starts the stream again from the beginning of the body. It is used as the http.Request.GetBody.
+func (s *bodyStream) replay() (io.ReadCloser, error) {
+	s.pipe.CloseWithError(errBodyNotSent)
+	<-s.done
+	if err := s.opt.BodyRewind(); err != nil {
+		return nil, err
+	}
+	s.pipe, s.done = streamPayload(s.opt)
+	return s.pipe, nil
+}
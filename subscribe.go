@@ -0,0 +1,127 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/caelisco/http-client/request"
+)
+
+// defaultMaxMessageSize is the largest message Subscribe reads when no maximum is set.
+const defaultMaxMessageSize = 16 << 20
+
+// Subscribe performs an HTTP GET to the specified URL and calls onMessage with each message of the streamed
+// response in order, i.e.: for NDJSON or long-poll APIs. Messages are separated by a line feed unless
+// RequestOptions.SetFraming is used. Empty lines are skipped.
+// Returns nil once the server closes the stream, otherwise the first error, including from onMessage.
+// Optionally, you can provide additional RequestOptions to customize the request.
+func Subscribe(url string, onMessage func([]byte) error, opt ...RequestOptions) error {
+	return subscribe(func(option RequestOptions) (Response, error) {
+		return doRequest(streamClient, http.MethodGet, url, nil, option)
+	}, onMessage, opt...)
+}
+
+// Subscribe performs an HTTP GET to the specified URL and reads the streamed response as a series of
+// messages. See Subscribe for how the messages are read.
+func (c *Client) Subscribe(url string, onMessage func([]byte) error, opt ...RequestOptions) error {
+	return subscribe(func(option RequestOptions) (Response, error) {
+		return c.doRequest(http.MethodGet, url, nil, option)
+	}, onMessage, opt...)
+}
+
+// subscribe makes the request with get in stream mode and reads the messages from the response.
+func subscribe(get func(RequestOptions) (Response, error), onMessage func([]byte) error, opt ...RequestOptions) error {
+	var option RequestOptions
+	if len(opt) == 0 {
+		option = request.NewOptions()
+	} else {
+		option = opt[0]
+	}
+	option.SetStreamOutput()
+	resp, err := get(option)
+	if err != nil {
+		return err
+	}
+	defer resp.Stream.Close()
+	if err = checkStatus(resp); err != nil {
+		return err
+	}
+	limit := option.MaxMessageSize
+	if limit <= 0 {
+		limit = defaultMaxMessageSize
+	}
+	if option.Framing == request.FramingLengthPrefix {
+		return readLengthPrefixed(resp.Stream, limit, onMessage)
+	}
+	return readLines(resp.Stream, limit, onMessage)
+}
+
+// readLines calls onMessage with each non-empty line, without its line ending.
+// A line longer than limit returns ErrMessageTooLarge.
+func readLines(r io.Reader, limit int, onMessage func([]byte) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := readLine(br, limit)
+		if line = bytes.TrimRight(line, "\r\n"); len(line) > 0 {
+			if cbErr := onMessage(line); cbErr != nil {
+				return cbErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readLine reads up to and including the next line feed, or to the end of the stream. Only the line
+// ending may take the line past limit.
+func readLine(br *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := br.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(bytes.TrimRight(line, "\r\n")) > limit {
+			return nil, fmt.Errorf("%w: line longer than %d bytes", ErrMessageTooLarge, limit)
+		}
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// readLengthPrefixed calls onMessage with each message, which follows its length as a 4 byte big endian integer.
+// A length larger than limit returns ErrMessageTooLarge before the message is read.
+func readLengthPrefixed(r io.Reader, limit int, onMessage func([]byte) error) error {
+	var prefix [4]byte
+	for {
+		if _, err := io.ReadFull(r, prefix[:]); err != nil {
+			// The stream may only end between messages
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := binary.BigEndian.Uint32(prefix[:])
+		if uint64(size) > uint64(limit) {
+			return fmt.Errorf("%w: message of %d bytes is larger than %d bytes", ErrMessageTooLarge, size, limit)
+		}
+		msg := make([]byte, size)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("stream ended within a message of %d bytes: %w", len(msg), io.ErrUnexpectedEOF)
+			}
+			return err
+		}
+		if err := onMessage(msg); err != nil {
+			return err
+		}
+	}
+}
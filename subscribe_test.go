@@ -0,0 +1,54 @@
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caelisco/http-client/request"
+)
+
+func TestSubscribeRejectsOversizedLengthPrefix(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A prefix claiming a 4 GiB message, followed by nothing
+		w.Write(binary.BigEndian.AppendUint32(nil, 0xFFFFFFFF))
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.SetFraming(request.FramingLengthPrefix)
+	err := Subscribe(server.URL, func([]byte) error { return nil }, opt)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("expected ErrMessageTooLarge, got %v", err)
+	}
+}
+
+func TestSubscribeRejectsOversizedLine(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("short\n" + strings.Repeat("x", 100) + "\n"))
+	}))
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.SetMaxMessageSize(10)
+	var messages []string
+	err := Subscribe(server.URL, func(msg []byte) error {
+		messages = append(messages, string(msg))
+		return nil
+	}, opt)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("expected ErrMessageTooLarge, got %v", err)
+	}
+	if len(messages) != 1 || messages[0] != "short" {
+		t.Fatalf("expected the short message before the error, got %q", messages)
+	}
+}
+
+func TestSubscribeHasNoOverallTimeout(t *testing.T) {
+	if streamClient.Timeout != 0 {
+		t.Fatalf("expected subscriptions to have no overall timeout, got %s", streamClient.Timeout)
+	}
+}
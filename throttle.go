@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// throttledReader limits the rate bytes are read from the underlying io.ReadCloser.
+// Reads are paced so the average rate since the first read does not exceed bytesPerSec.
+// Waiting is interrupted when the context is cancelled.
+type throttledReader struct {
+	reader      io.ReadCloser
+	ctx         context.Context
+	bytesPerSec int64
+	start       time.Time
+	read        int64
+}
+
+func newThrottledReader(ctx context.Context, r io.ReadCloser, bytesPerSec int64) *throttledReader {
+	return &throttledReader{reader: r, ctx: ctx, bytesPerSec: bytesPerSec}
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if tr.start.IsZero() {
+		tr.start = time.Now()
+	}
+	// Read at most a tenth of a second worth of data at a time to keep the rate smooth
+	chunk := tr.bytesPerSec / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := tr.reader.Read(p)
+	tr.read += int64(n)
+
+	// Wait until the bytes read so far are within the rate
+	due := tr.start.Add(time.Duration(float64(tr.read) / float64(tr.bytesPerSec) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-tr.ctx.Done():
+			return n, tr.ctx.Err()
+		}
+	}
+	return n, err
+}
+
+func (tr *throttledReader) Close() error {
+	return tr.reader.Close()
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/caelisco/http-client/kv"
+)
+
+// errUnauthorized stops the first attempt of a request with a token provider when the server responds
+// 401 Unauthorized, so the body is not read before the request is retried with a fresh token.
+var errUnauthorized = errors.New("unauthorized")
+
+// doRequestWithToken performs the request with a bearer token from the token provider. If the server
+// responds 401 Unauthorized, the provider is called again and the request is retried once. The response
+// to the retry is returned as it is, so a provider which keeps returning a rejected token cannot loop.
+// A request with a body which cannot be replayed is not retried.
+func doRequestWithToken(client *http.Client, method string, url string, payload []byte, opt RequestOptions) (Response, error) {
+	provider := opt.TokenProvider
+	opt.TokenProvider = nil
+
+	first, err := withToken(provider, opt)
+	if err != nil {
+		return Response{}, err
+	}
+	// A body stream which cannot be seeked back to its start is consumed by the first attempt,
+	// so the request is sent once and a 401 response is returned as it is
+	if opt.BodyStream != nil && opt.BodyRewind == nil {
+		return doRequest(client, method, url, payload, first)
+	}
+	// The rejected first attempt is not reported as the request completing
+	if complete, _ := opt.OnRequestComplete.(func(Response, error)); complete != nil {
+		first.OnRequestComplete = func(resp Response, err error) {
+			if !errors.Is(err, errUnauthorized) {
+				complete(resp, err)
+			}
+		}
+	}
+	first.ResponseInterceptors = append(slices.Clone(opt.ResponseInterceptors), func(resp *http.Response) error {
+		if resp.StatusCode == http.StatusUnauthorized {
+			return errUnauthorized
+		}
+		return nil
+	})
+	resp, err := doRequest(client, method, url, payload, first)
+	if !errors.Is(err, errUnauthorized) {
+		return resp, err
+	}
+
+	retry, err := withToken(provider, opt)
+	if err != nil {
+		return Response{}, err
+	}
+	if opt.BodyRewind != nil {
+		if err = opt.BodyRewind(); err != nil {
+			return Response{}, err
+		}
+	}
+	return doRequest(client, method, url, payload, retry)
+}
+
+// withToken returns a copy of the options with the Authorization header set to a bearer token from the provider.
+// The headers are copied so the options of other requests are not modified.
+func withToken(provider func(context.Context) (string, error), opt RequestOptions) (RequestOptions, error) {
+	ctx := context.Background()
+	if opt.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.Timeout)
+		defer cancel()
+	}
+	token, err := provider(ctx)
+	if err != nil {
+		return opt, fmt.Errorf("token provider: %w", err)
+	}
+	opt.Headers = slices.DeleteFunc(slices.Clone(opt.Headers), func(h kv.Header) bool {
+		return strings.EqualFold(h.Key, "Authorization")
+	})
+	opt.AddHeader("Authorization", "Bearer "+token)
+	return opt, nil
+}
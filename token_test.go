@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/caelisco/http-client/request"
+	"github.com/caelisco/http-client/response"
+)
+
+// tokenServer rejects the first token and echoes the body of a request with any other token.
+func tokenServer(requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(body)
+	}))
+}
+
+// countingProvider returns token-1, token-2 and so on.
+func countingProvider() func(context.Context) (string, error) {
+	n := 0
+	return func(context.Context) (string, error) {
+		n++
+		return "token-" + strconv.Itoa(n), nil
+	}
+}
+
+func TestTokenProviderRetriesWithFreshToken(t *testing.T) {
+	var requests int
+	server := tokenServer(&requests)
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.SetTokenProvider(countingProvider())
+	opt.SetBodyReader(strings.NewReader("payload"))
+	resp, err := Post(server.URL, nil, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.String() != "payload" {
+		t.Fatalf("expected the retry to send the whole body, got %d %q", resp.StatusCode, resp.String())
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestTokenProviderDoesNotRetryUnreplayableBody(t *testing.T) {
+	var requests int
+	server := tokenServer(&requests)
+	defer server.Close()
+
+	opt := request.NewOptions()
+	opt.SetTokenProvider(countingProvider())
+	// A MultiReader hides the io.Seeker of the strings.Reader
+	opt.SetBodyReader(io.MultiReader(strings.NewReader("payload")))
+	resp, err := Post(server.URL, nil, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the 401 response to be returned, got %d", resp.StatusCode)
+	}
+	if requests != 1 {
+		t.Fatalf("expected 1 request, got %d", requests)
+	}
+}
+
+func TestTokenRetryReportsOnlyFinalCompletion(t *testing.T) {
+	var requests int
+	server := tokenServer(&requests)
+	defer server.Close()
+
+	var completed []string
+	opt := request.NewOptions()
+	opt.SetTokenProvider(countingProvider())
+	opt.OnRequestComplete = func(resp response.Response, err error) {
+		completed = append(completed, fmt.Sprintf("%d %v", resp.StatusCode, err))
+	}
+	if _, err := Post(server.URL, []byte("payload"), opt); err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 1 || completed[0] != "200 <nil>" {
+		t.Fatalf("expected only the retried request to be reported, got %q", completed)
+	}
+}
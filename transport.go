@@ -0,0 +1,102 @@
+package client
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"crypto/x509"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// transportCacheSize is the most configured transports kept. The least recently used is closed beyond it.
+const transportCacheSize = 32
+
+// transports keeps the transports configured for the options of earlier requests, so requests with the same
+// options share one transport and reuse its connections and TLS sessions.
+var transports = newTransportCache(transportCacheSize)
+
+// transportKey identifies a transport by the transport it was cloned from and the options applied to it.
+type transportKey struct {
+	base                  *http.Transport
+	connectTimeout        time.Duration
+	localAddr             string
+	serverName            string
+	insecureSkipVerify    bool
+	certificates          [sha256.Size]byte
+	rootCAs               *x509.CertPool
+	minTLSVersion         uint16
+	sessionCache          bool
+	disableCompression    bool
+	expectContinueTimeout time.Duration
+	proxy                 string
+}
+
+// newTransportKey returns the key of the transport configured for the options from base.
+// Client certificates are compared by the digest of their chains, as the options hold a copy of the slice.
+func newTransportKey(base *http.Transport, opt RequestOptions) transportKey {
+	key := transportKey{
+		base:                  base,
+		connectTimeout:        opt.ConnectTimeout,
+		serverName:            opt.TLSServerName,
+		insecureSkipVerify:    opt.InsecureSkipVerify,
+		rootCAs:               opt.RootCAs,
+		minTLSVersion:         opt.MinTLSVersion,
+		sessionCache:          opt.TLSSessionCache,
+		disableCompression:    opt.DisableTransportCompression,
+		expectContinueTimeout: opt.ExpectContinueTimeout,
+	}
+	if opt.LocalAddr != nil {
+		key.localAddr = opt.LocalAddr.Network() + "/" + opt.LocalAddr.String()
+	}
+	if opt.Proxy != nil {
+		key.proxy = opt.Proxy.String()
+	}
+	if len(opt.ClientCertificates) > 0 {
+		digest := sha256.New()
+		for _, cert := range opt.ClientCertificates {
+			for _, der := range cert.Certificate {
+				digest.Write(der)
+			}
+			digest.Write([]byte{0})
+		}
+		copy(key.certificates[:], digest.Sum(nil))
+	}
+	return key
+}
+
+// transportCache is a least recently used cache of configured transports.
+type transportCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // Front is the most recently used
+	entries map[transportKey]*list.Element
+}
+
+type transportEntry struct {
+	key       transportKey
+	transport *http.Transport
+}
+
+func newTransportCache(size int) *transportCache {
+	return &transportCache{size: size, order: list.New(), entries: make(map[transportKey]*list.Element)}
+}
+
+// get returns the transport kept for the key, or keeps and returns the one built by build.
+func (tc *transportCache) get(key transportKey, build func() *http.Transport) *http.Transport {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if e, ok := tc.entries[key]; ok {
+		tc.order.MoveToFront(e)
+		return e.Value.(*transportEntry).transport
+	}
+	transport := build()
+	tc.entries[key] = tc.order.PushFront(&transportEntry{key: key, transport: transport})
+	if tc.order.Len() > tc.size {
+		oldest := tc.order.Remove(tc.order.Back()).(*transportEntry)
+		delete(tc.entries, oldest.key)
+		// Requests still using the transport keep their connections, only idle ones are closed
+		oldest.transport.CloseIdleConnections()
+	}
+	return transport
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caelisco/http-client/request"
+)
+
+func TestConfiguredTransportReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	c := New()
+	for i := 0; i < 3; i++ {
+		opt := request.NewOptions()
+		opt.RootCAs = pool
+		opt.TLSSessionCache = true
+		if _, err := c.Get(server.URL, opt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("expected the requests to share one connection, got %d", n)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	// Without the server's certificate authority, the server cannot be verified
+	if _, err := Get(server.URL); err == nil {
+		t.Fatal("expected an error verifying the server without its certificate authority")
+	}
+	// Without a client certificate, the server rejects the handshake
+	opt := request.NewOptions()
+	opt.SetRootCAs(pool)
+	if _, err := Get(server.URL, opt); err == nil {
+		t.Fatal("expected the server to reject a request without a client certificate")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt.SetClientCertificate(tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key})
+	resp, err := Get(server.URL, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "test client" {
+		t.Fatalf("expected the server to receive the client certificate, got %q", resp.String())
+	}
+}